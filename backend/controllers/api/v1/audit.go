@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UnlockUser godoc
+//
+//	@Summary		Unlock user
+//	@Description	lifts the lockout of a user after too many failed password or pin logins
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		401
+//	@Failure		403
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"User UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	var user models.User
+	if err := models.DB.Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	adminId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if err := auth.UnlockUser(&user, adminId, c.ClientIP()); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user.FailedLogins = 0
+	user.LockedUntil = nil
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+// FindAuditEvents godoc
+//
+//	@Summary		Find audit events
+//	@Description	lists failed and throttled logins, lockouts and unlocks, newest first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.AuditEvent
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403
+//	@Failure		500
+//
+//	@Param			type	query	string	false	"Event type"	Enums(login_failed, login_throttled, account_locked, account_unlocked)
+//	@Param			user_id	query	string	false	"User UUID"
+//	@Param			limit	query	int		false	"Maximum number of events"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/audit-events [get]
+func FindAuditEvents(c *gin.Context) {
+	var events []models.AuditEvent
+
+	limit := c.DefaultQuery("limit", "100")
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	query := models.DB.Order("created_at DESC").Limit(limitInt)
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if userId := c.Query("user_id"); userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+	query.Find(&events)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": events})
+}
@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"net/http"
+
+	"metalab/metadrinks/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreateCategoryInput struct {
+	Name      string `json:"name" binding:"required"`
+	SortIndex int    `json:"sort_index"`
+}
+
+// CreateCategory godoc
+//
+//	@Summary		Create category
+//	@Description	create new item category
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Category
+//	@Failure		400
+//	@Failure		401
+//	@Failure		500
+//
+//	@Param			category	body	CreateCategoryInput	true	"Create category"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/categories [post]
+func CreateCategory(c *gin.Context) {
+	var input CreateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := models.Category{Name: input.Name, SortIndex: input.SortIndex}
+	if err := models.DB.Create(&category).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": category})
+}
+
+// FindCategories godoc
+//
+//	@Summary		Find categories
+//	@Description	get categories in sort order
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Category
+//	@Failure		500
+//
+//	@Router			/categories [get]
+func FindCategories(c *gin.Context) {
+	var categories []models.Category
+	models.DB.Order("sort_index ASC, name ASC").Find(&categories)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": categories})
+}
+
+// FindCategory godoc
+//
+//	@Summary		Find category
+//	@Description	get specific category
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Category
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Category UUID"
+//
+//	@Router			/categories/{id} [get]
+func FindCategory(c *gin.Context) {
+	var category models.Category
+
+	if err := models.DB.Where("category_id = ?", c.Param("id")).First(&category).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": category})
+}
+
+type UpdateCategoryInput struct {
+	Name      string `json:"name,omitempty"`
+	SortIndex *int   `json:"sort_index,omitempty"`
+}
+
+// UpdateCategory godoc
+//
+//	@Summary		Update category
+//	@Description	update specific category
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Category
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Param			id			path	string				true	"Category UUID"
+//	@Param			category	body	UpdateCategoryInput	true	"Update category"
+//
+//	@Router			/categories/{id} [put]
+func UpdateCategory(c *gin.Context) {
+	var category models.Category
+	if err := models.DB.Where("category_id = ?", c.Param("id")).First(&category).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	var input UpdateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedCategory := map[string]any{}
+	if input.Name != "" {
+		updatedCategory["name"] = input.Name
+	}
+	if input.SortIndex != nil {
+		updatedCategory["sort_index"] = *input.SortIndex
+	}
+
+	if err := models.DB.Model(&category).Updates(updatedCategory).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": category})
+}
+
+// DeleteCategory godoc
+//
+//	@Summary		Delete category
+//	@Description	delete specific category - its items are kept without a category
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string} string	"success"
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Param			id	path	string	true	"Category UUID"
+//
+//	@Router			/categories/{id} [delete]
+func DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if err := models.DB.Where("category_id = ?", c.Param("id")).First(&category).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Item{}).Where("category_id = ?", category.CategoryId).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+type SortInput struct {
+	Id        uuid.UUID `json:"id" binding:"required"`
+	SortIndex int       `json:"sort_index"`
+}
+
+// SortCategories godoc
+//
+//	@Summary		Sort categories
+//	@Description	sets the sort index of multiple categories at once
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Category
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Param			order	body	[]SortInput	true	"New sort order"
+//
+//	@Router			/categories/sort [put]
+func SortCategories(c *gin.Context) {
+	var input []SortInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := applySortOrder(&models.Category{}, "category_id", input); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	FindCategories(c)
+}
+
+// applySortOrder updates the sort index of all given rows of model in a single transaction. If one of the rows does
+// not exist, nothing is changed.
+func applySortOrder(model any, idColumn string, input []SortInput) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		for _, v := range input {
+			result := tx.Model(model).Where(idColumn+" = ?", v.Id).Update("sort_index", v.SortIndex)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,221 @@
+package v1
+
+import (
+	"net/http"
+
+	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateDeviceInput struct {
+	Name            string `json:"name" binding:"required"`
+	DefaultReaderId string `json:"default_reader_id"`
+}
+
+// DeviceWithCredential is only returned when a device is registered or its credential is rotated, as the credential
+// cannot be retrieved afterwards.
+type DeviceWithCredential struct {
+	models.Device
+	Credential string `json:"credential"`
+}
+
+// CreateDevice godoc
+//
+//	@Summary		Register device
+//	@Description	registers a kiosk - the returned credential has to be sent by the kiosk in the X-Device-Token header and is only shown once
+//	@Tags			devices
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	DeviceWithCredential
+//	@Failure		400
+//	@Failure		401
+//	@Failure		500
+//
+//	@Param			device	body	CreateDeviceInput	true	"Register device"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/devices [post]
+func CreateDevice(c *gin.Context) {
+	var input CreateDeviceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := auth.GenerateSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	device := models.Device{Name: input.Name, CredentialHash: models.HashSecret(credential), DefaultReaderId: input.DefaultReaderId, CreatedBy: userId}
+	if err := models.DB.Create(&device).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": DeviceWithCredential{Device: device, Credential: credential}})
+}
+
+// FindDevices godoc
+//
+//	@Summary		Find devices
+//	@Description	lists all registered kiosks
+//	@Tags			devices
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Device
+//	@Failure		401
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/devices [get]
+func FindDevices(c *gin.Context) {
+	var devices []models.Device
+	models.DB.Order("name ASC").Find(&devices)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": devices})
+}
+
+// FindCurrentDevice godoc
+//
+//	@Summary		Find current device
+//	@Description	returns the kiosk the request was made from, e.g. to look up its default reader
+//	@Tags			devices
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Device
+//	@Failure		401
+//
+//	@Router			/devices/me [get]
+func FindCurrentDevice(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": auth.CurrentDevice(c)})
+}
+
+type UpdateDeviceInput struct {
+	Name            string  `json:"name,omitempty"`
+	DefaultReaderId *string `json:"default_reader_id,omitempty"`
+}
+
+// UpdateDevice godoc
+//
+//	@Summary		Update device
+//	@Description	renames a kiosk or changes its default reader
+//	@Tags			devices
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Device
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id		path	string				true	"Device UUID"
+//	@Param			device	body	UpdateDeviceInput	true	"Update device"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/devices/{id} [patch]
+func UpdateDevice(c *gin.Context) {
+	var device models.Device
+	if err := models.DB.Where("device_id = ?", c.Param("id")).First(&device).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	var input UpdateDeviceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedDevice := map[string]any{}
+	if input.Name != "" {
+		updatedDevice["name"] = input.Name
+	}
+	if input.DefaultReaderId != nil {
+		updatedDevice["default_reader_id"] = *input.DefaultReaderId
+	}
+
+	if err := models.DB.Model(&device).Updates(updatedDevice).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": device})
+}
+
+// RotateDeviceCredential godoc
+//
+//	@Summary		Rotate device credential
+//	@Description	replaces the credential of a kiosk - the old credential stops working immediately
+//	@Tags			devices
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	DeviceWithCredential
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Device UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/devices/{id}/credential [post]
+func RotateDeviceCredential(c *gin.Context) {
+	var device models.Device
+	if err := models.DB.Where("device_id = ?", c.Param("id")).First(&device).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	credential, err := auth.GenerateSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.DB.Model(&device).Update("credential_hash", models.HashSecret(credential)).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": DeviceWithCredential{Device: device, Credential: credential}})
+}
+
+// DeleteDevice godoc
+//
+//	@Summary		Delete device
+//	@Description	removes a kiosk - its credential stops working immediately
+//	@Tags			devices
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Device UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/devices/{id} [delete]
+func DeleteDevice(c *gin.Context) {
+	result := models.DB.Where("device_id = ?", c.Param("id")).Delete(&models.Device{})
+	if result.Error != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
@@ -10,9 +10,12 @@ import (
 )
 
 type CreateItemInput struct {
-	Name  string `json:"name" binding:"required"`
-	Image string `json:"image"`
-	Price uint   `json:"price" binding:"required"`
+	Name              string     `json:"name" binding:"required"`
+	Image             string     `json:"image"`
+	Price             uint       `json:"price" binding:"required"`
+	CategoryId        *uuid.UUID `json:"category_id"`
+	SortIndex         int        `json:"sort_index"`
+	LowStockThreshold int        `json:"low_stock_threshold" binding:"min=0"`
 }
 
 //	@BasePath	/api/v1
@@ -40,7 +43,12 @@ func CreateItem(c *gin.Context) {
 		return
 	}
 
-	item := models.Item{Name: input.Name, Image: input.Image, Price: input.Price}
+	if input.CategoryId != nil && !categoryExists(*input.CategoryId) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "category not found"})
+		return
+	}
+
+	item := models.Item{Name: input.Name, Image: input.Image, Price: input.Price, CategoryId: input.CategoryId, SortIndex: input.SortIndex, LowStockThreshold: input.LowStockThreshold}
 	if err := models.DB.Create(&item).Error; err != nil {
 		c.AbortWithStatus(http.StatusBadRequest /*, gin.H{"error": err.Error()}*/)
 		return
@@ -49,22 +57,78 @@ func CreateItem(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": item})
 }
 
+// ItemGroup contains all items of a category. Category is null for items without a category.
+type ItemGroup struct {
+	Category *models.Category `json:"category"`
+	Items    []models.Item    `json:"items"`
+}
+
 // FindItems godoc
 //
 //	@Summary		Find items
-//	@Description	get items
+//	@Description	get items in sort order - optionally filtered by category or grouped by category
 //	@Tags			items
 //	@Accept			json
 //	@Produce		json
 //	@Success		200	{object}	[]models.Item
+//	@Success		200	{object}	[]ItemGroup	"if grouped by category"
+//	@Failure		400
 //	@Failure		500
+//
+//	@Param			category	query	string	false	"Category UUID"
+//	@Param			group		query	string	false	"Set to 'category' to group the items by category"	Enums(category)
+//
 //	@Router			/items [get]
 func FindItems(c *gin.Context) {
 	var items []models.Item
-	models.DB.Find(&items).Order("sort_index ASC")
+
+	query := models.DB.Order("sort_index ASC, name ASC")
+	if category := c.Query("category"); category != "" {
+		categoryId, err := uuid.Parse(category)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("category_id = ?", categoryId)
+	}
+	query.Find(&items)
 
 	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, gin.H{"data": items})
+	if c.Query("group") != "category" {
+		c.JSON(http.StatusOK, gin.H{"data": items})
+		return
+	}
+
+	var categories []models.Category
+	models.DB.Order("sort_index ASC, name ASC").Find(&categories)
+
+	groups := make([]ItemGroup, 0, len(categories)+1)
+	groupIndex := make(map[uuid.UUID]int)
+	for i := range categories {
+		groupIndex[categories[i].CategoryId] = len(groups)
+		groups = append(groups, ItemGroup{Category: &categories[i]})
+	}
+	groups = append(groups, ItemGroup{}) // items without a category come last
+
+	for _, v := range items {
+		index := len(groups) - 1
+		if v.CategoryId != nil {
+			if categoryIndex, ok := groupIndex[*v.CategoryId]; ok {
+				index = categoryIndex
+			}
+		}
+		groups[index].Items = append(groups[index].Items, v)
+	}
+
+	nonEmptyGroups := []ItemGroup{}
+	for _, v := range groups {
+		if len(v.Items) != 0 {
+			nonEmptyGroups = append(nonEmptyGroups, v)
+		}
+	}
+	groups = nonEmptyGroups
+
+	c.JSON(http.StatusOK, gin.H{"data": groups})
 }
 
 // FindItem godoc
@@ -93,20 +157,23 @@ func FindItem(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": item})
 }
 
-func FindItemById(id uuid.UUID) models.Item {
+func FindItemById(id uuid.UUID) (*models.Item, error) {
 	var item models.Item
 
 	if err := models.DB.Where("item_id = ?", id).First(&item).Error; err != nil {
-		return models.Item{Name: "No item found", Price: 0}
+		return nil, err
 	}
 
-	return item
+	return &item, nil
 }
 
 type UpdateItemInput struct {
-	Name  string `json:"name,omitempty"`
-	Image string `json:"image,omitempty"`
-	Price uint   `json:"price,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Image             string     `json:"image,omitempty"`
+	Price             uint       `json:"price,omitempty"`
+	CategoryId        *uuid.UUID `json:"category_id,omitempty"`
+	SortIndex         *int       `json:"sort_index,omitempty"`
+	LowStockThreshold *int       `json:"low_stock_threshold,omitempty" binding:"omitempty,min=0"`
 }
 
 // UpdateItem godoc
@@ -140,12 +207,56 @@ func UpdateItem(c *gin.Context) {
 		return
 	}
 
-	updatedItem := models.Item{Name: input.Name, Image: input.Image, Price: input.Price}
+	if input.CategoryId != nil && !categoryExists(*input.CategoryId) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "category not found"})
+		return
+	}
+
+	updatedItem := models.Item{Name: input.Name, Image: input.Image, Price: input.Price, CategoryId: input.CategoryId}
 
 	models.DB.Model(&item).Updates(&updatedItem)
+	if input.SortIndex != nil { // Updates skips zero values, but 0 is a valid sort index
+		models.DB.Model(&item).Update("sort_index", *input.SortIndex)
+	}
+	if input.LowStockThreshold != nil { // Updates skips zero values, but 0 disables the alerts
+		models.DB.Model(&item).Update("low_stock_threshold", *input.LowStockThreshold)
+	}
 	c.JSON(http.StatusOK, gin.H{"data": item})
 }
 
+// SortItems godoc
+//
+//	@Summary		Sort items
+//	@Description	sets the sort index of multiple items at once
+//	@Tags			items
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Item
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Param			order	body	[]SortInput	true	"New sort order"
+//
+//	@Router			/items/sort [put]
+func SortItems(c *gin.Context) {
+	var input []SortInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := applySortOrder(&models.Item{}, "item_id", input); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	FindItems(c)
+}
+
 // DeleteItem godoc
 //
 //	@Summary		Delete item
@@ -173,3 +284,9 @@ func DeleteItem(c *gin.Context) {
 	models.DB.Delete(&item)
 	c.JSON(http.StatusOK, gin.H{"data": "success"})
 }
+
+func categoryExists(id uuid.UUID) bool {
+	var count int64
+	models.DB.Model(&models.Category{}).Where("category_id = ?", id).Count(&count)
+	return count != 0
+}
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// FindUserLedger godoc
+//
+//	@Summary		Find user ledger
+//	@Description	lists the balance transactions of a user, newest first - only the user themselves and users with the balances.manage permission can see them
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.BalanceTransaction
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403
+//	@Failure		500
+//
+//	@Param			id		path	string	true	"User UUID"
+//	@Param			limit	query	int		false	"Maximum number of transactions"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id}/ledger [get]
+func FindUserLedger(c *gin.Context) {
+	var transactions []models.BalanceTransaction
+	userClaims := jwt.ExtractClaims(c)
+
+	if userClaims["userId"].(string) != c.Param("id") && !auth.CurrentUser(c).HasPermission(models.PermissionBalancesManage) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	limit := c.DefaultQuery("limit", "-1")
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	models.DB.Where("user_id = ?", c.Param("id")).Order("created_at DESC").Limit(limitInt).Find(&transactions)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": transactions})
+}
@@ -0,0 +1,360 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type CurrentUserResponse struct {
+	models.PrivateUser
+	RecentPurchases []models.Purchase `json:"recent_purchases"`
+}
+
+// FindCurrentUser godoc
+//
+//	@Summary		Find current user
+//	@Description	returns the logged-in user with their current balance, flags and most recent purchases
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	CurrentUserResponse
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			purchases	query	int	false	"Number of recent purchases, defaults to 10"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me [get]
+func FindCurrentUser(c *gin.Context) {
+	var user models.User
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+
+	limit, err := strconv.Atoi(c.DefaultQuery("purchases", "10"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var purchases []models.Purchase
+	models.DB.Preload("Items").Where("created_by = ?", userId).Order("created_at DESC").Limit(limit).Find(&purchases)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": CurrentUserResponse{PrivateUser: user.Private(), RecentPurchases: purchases}})
+}
+
+type UpdateCurrentUserInput struct {
+	Name  *string `json:"name,omitempty"`
+	Image *string `json:"image,omitempty"`
+}
+
+// UpdateCurrentUser godoc
+//
+//	@Summary		Update current user
+//	@Description	updates the name and image of the logged-in user - only the given fields are changed
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			user	body	UpdateCurrentUserInput	true	"Update current user"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me [patch]
+func UpdateCurrentUser(c *gin.Context) {
+	var user models.User
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	var input UpdateCurrentUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedUser := map[string]any{}
+	if input.Name != nil {
+		if *input.Name == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		updatedUser["name"] = *input.Name
+	}
+	if input.Image != nil {
+		updatedUser["image"] = *input.Image
+	}
+
+	if err := models.DB.Model(&user).Updates(updatedUser).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+type UpdateCurrentUserImageInput struct {
+	Image string `json:"image" binding:"required"`
+}
+
+// UpdateCurrentUserImage godoc
+//
+//	@Summary		Update current user image
+//	@Description	sets the avatar of the logged-in user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			image	body	UpdateCurrentUserImageInput	true	"New image"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/image [put]
+func UpdateCurrentUserImage(c *gin.Context) {
+	var user models.User
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	var input UpdateCurrentUserImageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.DB.Model(&user).Update("image", input.Image).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+type ChangePasswordInput struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=72"` // bcrypt only uses the first 72 bytes
+}
+
+// ChangeCurrentUserPassword godoc
+//
+//	@Summary		Change password
+//	@Description	changes the password of the logged-in user and logs out all their other sessions - the old password has to be given, unless a user created by the single sign-on sets their first password within FRESH_SESSION_MAX_AGE after logging in
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403	"old password is wrong"
+//	@Failure		403	"log in again before setting a password"
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			password	body	ChangePasswordInput	true	"Change password"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/password [put]
+func ChangeCurrentUserPassword(c *gin.Context) {
+	var user models.User
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	if user.UserID == uuid.Nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the password of the guest user cannot be changed"})
+		return
+	}
+
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// users created by the single sign-on have no password yet and can set one right after logging in
+	if user.Password == "" && user.OidcSubject != nil {
+		if !auth.IsSessionFresh(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "log in again before setting a password"})
+			return
+		}
+	} else if err := auth.VerifyPassword(input.OldPassword, user.Password); err != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "old password is wrong"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.DB.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := auth.RevokeOtherSessions(c, user.UserID); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+type SetPinInput struct {
+	Pin      string `json:"pin" binding:"required"`
+	Password string `json:"password"` // the current password of the user, not needed for users without a password
+}
+
+// SetCurrentUserPin godoc
+//
+//	@Summary		Set pin
+//	@Description	sets the 4 to 6 digit pin of the logged-in user, which can be used to log in at the kiosk instead of the password - the current password has to be given, users without a password have to have logged in within FRESH_SESSION_MAX_AGE
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		400	"pin must consist of 4 to 6 digits"
+//	@Failure		401
+//	@Failure		403	"password is wrong"
+//	@Failure		403	"log in again before changing the pin"
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			pin	body	SetPinInput	true	"Set pin"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/pin [put]
+func SetCurrentUserPin(c *gin.Context) {
+	var user models.User
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	if user.UserID == uuid.Nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the guest user cannot have a pin"})
+		return
+	}
+
+	var input SetPinInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !confirmPinChange(c, &user, input.Password) {
+		return
+	}
+
+	hashedPin, err := auth.HashPin(input.Pin)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.DB.Model(&user).Updates(map[string]any{"pin_hash": hashedPin, "pin_attempts": 0, "pin_lock_until": nil}).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+// confirmPinChange checks that the pin is changed by the user themselves and not just by someone holding their token or
+// pin session, because the pin protects larger balance payments. Users created by the single sign-on have no
+// password, they prove who they are by having logged in just now. It responds with an error and returns false if the
+// check fails.
+func confirmPinChange(c *gin.Context, user *models.User, password string) bool {
+	if user.Password == "" && user.OidcSubject != nil {
+		if !auth.IsSessionFresh(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "log in again before changing the pin"})
+			return false
+		}
+		return true
+	}
+
+	if err := auth.VerifyPassword(password, user.Password); err != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "password is wrong"})
+		return false
+	}
+	return true
+}
+
+type DeletePinInput struct {
+	Password string `json:"password"` // the current password of the user, not needed for users without a password
+}
+
+// DeleteCurrentUserPin godoc
+//
+//	@Summary		Delete pin
+//	@Description	removes the pin of the logged-in user - the current password has to be given, users without a password have to have logged in within FRESH_SESSION_MAX_AGE
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403	"password is wrong"
+//	@Failure		403	"log in again before changing the pin"
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			pin	body	DeletePinInput	true	"Delete pin"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/pin [delete]
+func DeleteCurrentUserPin(c *gin.Context) {
+	var user models.User
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	var input DeletePinInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !confirmPinChange(c, &user, input.Password) {
+		return
+	}
+
+	if err := models.DB.Model(&user).Updates(map[string]any{"pin_hash": "", "pin_attempts": 0, "pin_lock_until": nil}).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
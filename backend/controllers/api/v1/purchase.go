@@ -1,12 +1,15 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"metalab/metadrinks/controllers/auth"
+	paymentv1 "metalab/metadrinks/controllers/payment/v1"
 	"metalab/metadrinks/libs"
 	"metalab/metadrinks/models"
 	sumupmodels "metalab/metadrinks/models/sumup"
@@ -14,19 +17,33 @@ import (
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// maxItemQuantity is the largest quantity of a single item in one purchase, which keeps quantities far from the
+// limits of int when they are turned into stock changes.
+const maxItemQuantity = 1000
+
+type PurchaseItemInput struct {
+	ItemId   uuid.UUID `json:"item_id" binding:"required"`
+	Quantity uint      `json:"quantity" binding:"required,min=1"` // at most maxItemQuantity per item, checked by createPurchase
+}
+
+var errFinalCostTooHigh = errors.New("final cost exceeds maximum allowed value")
+
 type CreatePurchaseInput struct {
-	Items       []models.Item      `json:"items"`
-	PaymentType models.PaymentType `json:"payment_type" binding:"required"`
-	Amount      uint               `json:"amount"` // used only for adding balance
-	ReaderId    string             `json:"reader_id"`
+	Items       []PurchaseItemInput `json:"items" binding:"dive"`
+	PaymentType models.PaymentType  `json:"payment_type" binding:"required"`
+	Amount      uint                `json:"amount"` // used only for topping up the balance, with card or cash
+	ReaderId    string              `json:"reader_id"`
+	Pin         string              `json:"pin,omitempty"` // needed for balance payments above PIN_REQUIRED_ABOVE if the user has a pin
 }
 
 // CreatePurchase godoc
 //
 //	@Summary		Create purchase
-//	@Description	create new purchase - only item id is needed in initial creation request
+//	@Description	create new purchase - only item id and quantity are needed for each purchased item. Guests can only buy at registered kiosks, which also provide the default reader for card payments. To top up the balance, set amount instead of items: card top-ups are credited once the card payment is successful, cash top-ups once an admin or trusted user confirmed them.
 //	@Tags			purchases
 //	@Accept			json
 //	@Produce		json
@@ -34,10 +51,17 @@ type CreatePurchaseInput struct {
 //	@Failure		400 "Bad Request"
 //	@Failure		400	"only one of 'items' and 'amount' can be specified"
 //	@Failure		400	"final cost exceeds maximum allowed value"
+//	@Failure		400	"top-ups cannot be paid with balance"
 //	@Failure		401 "Unauthorized"
+//	@Failure		401	"guest purchases are only possible at registered kiosks"
+//	@Failure		404	"item not found"
 //	@Failure		403 "Forbidden"
+//	@Failure		403	"user is inactive"
 //	@Failure		403	"user is restricted"
 //	@Failure		403	"not enough balance"
+//	@Failure		403	"wrong pin"
+//	@Failure		429	"pin login is locked"
+//	@Failure		409	"item is out of stock"
 //	@Failure		500 "Internal Server Error"
 //	@Failure		500	"error while creating reader checkout"
 //
@@ -48,35 +72,131 @@ type CreatePurchaseInput struct {
 //	@Router			/purchases [post]
 func CreatePurchase(c *gin.Context) {
 	var input CreatePurchaseInput
-	var finalCost uint = 0
-	clientTransactionId := ""
-	var transactionDescription []string
-	var transactionStatus sumupmodels.TransactionFullStatus
-	var returnedItemsArray []models.Item
 	userClaims := jwt.ExtractClaims(c)
 	userId := uuid.MustParse(userClaims["userId"].(string))
-	userTrust := userClaims["trusted"].(bool)
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
 
-	if input.Amount != 0 && len(input.Items) != 0 {
-		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("only one of 'items' and 'amount' can be specified"))
+	device := auth.CurrentDevice(c)
+	if userId == uuid.Nil && device == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "guest purchases are only possible at registered kiosks"})
 		return
 	}
+	if input.ReaderId == "" && device != nil {
+		input.ReaderId = device.DefaultReaderId
+	}
 
-	if input.Amount != 0 && userClaims["restricted"].(bool) {
-		c.AbortWithError(http.StatusForbidden, fmt.Errorf("user is restricted"))
+	purchase, movements, err := createPurchase(userId, input)
+	if err != nil {
+		var pErr *purchaseError
+		if errors.As(err, &pErr) {
+			c.AbortWithError(pErr.Status, pErr.Err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	for _, v := range input.Items {
-		item := FindItemById(v.ItemId)
-		finalCost += item.Price
-		returnedItemsArray = append(returnedItemsArray, models.Item{ItemId: v.ItemId, Name: item.Name, Price: item.Price})
-		transactionDescription = append(transactionDescription, fmt.Sprintf("%s", item.Name))
+	if warnings := outOfStockWarnings(purchase, movements); len(warnings) != 0 {
+		c.JSON(http.StatusOK, gin.H{"data": purchase, "warnings": warnings})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": purchase})
+}
+
+var (
+	errUserInactive     = errors.New("user is inactive")
+	errUserRestricted   = errors.New("user is restricted")
+	errNotEnoughBalance = errors.New("not enough balance")
+)
+
+// purchaseError is returned by createPurchase for errors caused by the request, together with the http status the
+// handler should respond with.
+type purchaseError struct {
+	Status int
+	Err    error
+}
+
+func (e *purchaseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *purchaseError) Unwrap() error {
+	return e.Err
+}
+
+// createPurchase creates a purchase for the user. The balance check, the balance change, the stock changes and the
+// purchase itself are written in a single transaction which locks the row of the user, so concurrent purchases of
+// the same user are serialized and cannot overdraw the balance.
+func createPurchase(userId uuid.UUID, input CreatePurchaseInput) (*models.Purchase, []models.StockMovement, error) {
+	clientTransactionId := ""
+	var transactionDescription []string
+	var transactionStatus sumupmodels.TransactionFullStatus
+	var purchaseItems []models.PurchaseItem
+
+	if input.Amount != 0 && len(input.Items) != 0 {
+		return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("only one of 'items' and 'amount' can be specified")}
+	}
+	if input.Amount != 0 && input.PaymentType == models.PaymentTypeBalance {
+		return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("top-ups cannot be paid with balance")}
+	}
+	if input.Amount != 0 && userId == uuid.Nil {
+		return nil, nil, &purchaseError{http.StatusForbidden, fmt.Errorf("guests cannot top up balance")}
+	}
+
+	quantities := make(map[uuid.UUID]uint)
+	var itemOrder []uuid.UUID
+	for _, v := range input.Items { // merge lines which reference the same item
+		if _, ok := quantities[v.ItemId]; !ok {
+			itemOrder = append(itemOrder, v.ItemId)
+		}
+		// checked before adding, so huge quantities cannot wrap around when lines are merged
+		if v.Quantity == 0 || v.Quantity > maxItemQuantity || quantities[v.ItemId]+v.Quantity > maxItemQuantity {
+			return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("item %s: quantity must be between 1 and %d", v.ItemId, maxItemQuantity)}
+		}
+		quantities[v.ItemId] += v.Quantity
+	}
+
+	var finalCost uint
+	for _, itemId := range itemOrder {
+		item, err := FindItemById(itemId)
+		if err != nil {
+			return nil, nil, &purchaseError{http.StatusNotFound, fmt.Errorf("item %s not found", itemId)}
+		}
+		quantity := quantities[itemId]
+		if GetOutOfStockPolicy() == OutOfStockPolicyRefuse && item.Stock < int(quantity) {
+			return nil, nil, &purchaseError{http.StatusConflict, fmt.Errorf("%s: %w", item.Name, models.ErrOutOfStock)}
+		}
+		// check for overflows before multiplying and adding, the final cost has to fit into the balance
+		if item.Price > math.MaxInt32/quantity {
+			return nil, nil, &purchaseError{http.StatusBadRequest, errFinalCostTooHigh}
+		}
+		lineTotal := item.Price * quantity
+		if finalCost > math.MaxInt32-lineTotal {
+			return nil, nil, &purchaseError{http.StatusBadRequest, errFinalCostTooHigh}
+		}
+		finalCost += lineTotal
+
+		purchaseItems = append(purchaseItems, models.PurchaseItem{ItemId: itemId, Name: item.Name, Quantity: quantity, UnitPrice: item.Price, LineTotal: lineTotal})
+		transactionDescription = append(transactionDescription, fmt.Sprintf("%dx %s", quantity, item.Name))
+	}
+
+	if input.Amount != 0 {
+		transactionDescription = append(transactionDescription, "balance top-up")
+	}
+
+	purchase := models.Purchase{Items: purchaseItems, PaymentType: input.PaymentType, TopUpAmount: input.Amount, CreatedBy: userId}
+	if finalCost >= math.MaxInt32 || input.Amount >= math.MaxInt32 {
+		return nil, nil, &purchaseError{http.StatusBadRequest, errFinalCostTooHigh}
+	}
+
+	if input.PaymentType == models.PaymentTypeBalance {
+		if err := checkPurchasePin(userId, finalCost, input.Pin); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	finalTransactionDescription := strings.Join(transactionDescription[:], ", ")
@@ -84,43 +204,92 @@ func CreatePurchase(c *gin.Context) {
 	case models.PaymentTypeCard:
 		var err error
 		transactionStatus = sumupmodels.TransactionFullStatusPending
-		clientTransactionId, err = libs.StartReaderCheckout(input.ReaderId, finalCost, &finalTransactionDescription)
+		clientTransactionId, err = libs.Provider.CreateReaderCheckout(input.ReaderId, finalCost+input.Amount, &finalTransactionDescription)
 		if err != nil {
 			fmt.Printf("error while creating reader checkout: %s\n", err.Error())
-			c.AbortWithError(http.StatusInternalServerError, err)
-			return
+			return nil, nil, err
 		}
-	case models.PaymentTypeCash:
+	case models.PaymentTypeCash, models.PaymentTypeBalance:
 		transactionStatus = sumupmodels.TransactionFullStatusSuccessful
-	case models.PaymentTypeBalance:
-		if balance, err := GetUserBalance(userId); err == nil {
-			if finalCost >= math.MaxInt32 {
-				c.AbortWithError(http.StatusBadRequest, fmt.Errorf("final cost exceeds maximum allowed value"))
-				return
+		if input.Amount != 0 { // cash top-ups are credited once they are confirmed
+			transactionStatus = sumupmodels.TransactionFullStatusPending
+		}
+	default:
+		return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("unknown payment type %q", input.PaymentType)}
+	}
+
+	purchase.ClientTransactionId = clientTransactionId
+	purchase.TransactionStatus = transactionStatus
+	purchase.FinalCost = finalCost
+	var movements []models.StockMovement
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		user, err := GetUserForUpdate(tx, userId)
+		if err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return &purchaseError{http.StatusForbidden, errUserInactive}
+		}
+		if user.IsRestricted && (input.PaymentType == models.PaymentTypeBalance || input.Amount != 0) {
+			return &purchaseError{http.StatusForbidden, errUserRestricted}
+		}
+		if input.PaymentType == models.PaymentTypeBalance && user.Balance-int(finalCost) < -user.CalculateCreditLimit() {
+			return &purchaseError{http.StatusForbidden, errNotEnoughBalance}
+		}
+
+		if err := tx.Create(&purchase).Error; err != nil {
+			return err
+		}
+		if movements, err = sellPurchaseItems(tx, &purchase); err != nil {
+			if errors.Is(err, models.ErrOutOfStock) {
+				return &purchaseError{http.StatusConflict, err}
 			}
-			if (*balance-int(finalCost) < 0) && !userTrust {
-				c.AbortWithError(http.StatusForbidden, fmt.Errorf("not enough balance"))
-				return
-			} else {
-				transactionStatus = sumupmodels.TransactionFullStatusSuccessful
-				UpdateUserBalance(userId, -int(finalCost))
+			return err
+		}
+		if input.PaymentType == models.PaymentTypeBalance && finalCost != 0 {
+			return models.ChangeBalance(tx, &models.BalanceTransaction{UserId: userId, Type: models.BalanceTransactionTypePurchase, Amount: -int(finalCost), PurchaseId: &purchase.PurchaseId, Note: finalTransactionDescription, CreatedBy: userId})
+		}
+		return nil
+	})
+	if err != nil {
+		if input.PaymentType == models.PaymentTypeCard { // the purchase was not stored, so the checkout must not go through
+			if terminateErr := libs.Provider.TerminateReaderCheckout(input.ReaderId); terminateErr != nil {
+				fmt.Printf("error while terminating checkout: %s\n", terminateErr.Error())
 			}
-		} else if err.Error() == "user is restricted" {
-			c.AbortWithError(http.StatusForbidden, err)
-			return
-		} else {
-			c.AbortWithError(http.StatusInternalServerError, err)
-			return
 		}
+		return nil, nil, err
 	}
 
-	purchase := models.Purchase{Items: returnedItemsArray, PaymentType: input.PaymentType, ClientTransactionId: clientTransactionId, TransactionStatus: transactionStatus, FinalCost: finalCost, RefundAmount: input.Amount, CreatedBy: userId}
-	models.DB.Create(&purchase)
-	if input.Amount != 0 {
-		UpdateUserBalance(userId, int(input.Amount))
+	for _, v := range movements {
+		checkLowStock(v)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": purchase})
+	return &purchase, movements, nil
+}
+
+// checkPurchasePin verifies the pin of users who have one if the balance payment costs more than PIN_REQUIRED_ABOVE.
+// If PIN_REQUIRED_ABOVE is not set, no pin is needed.
+func checkPurchasePin(userId uuid.UUID, finalCost uint, pin string) error {
+	requiredAbove := libs.GetEnvInt("PIN_REQUIRED_ABOVE", -1)
+	if requiredAbove < 0 || int(finalCost) <= requiredAbove {
+		return nil
+	}
+
+	var user models.User
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		return err
+	}
+	if user.PinHash == "" {
+		return nil
+	}
+
+	if err := auth.VerifyPin(&user, pin); err != nil {
+		if errors.Is(err, auth.ErrPinLocked) {
+			return &purchaseError{http.StatusTooManyRequests, err}
+		}
+		return &purchaseError{http.StatusForbidden, err}
+	}
+	return nil
 }
 
 // FindPurchases godoc
@@ -149,7 +318,7 @@ func FindPurchases(c *gin.Context) {
 		return
 	}
 
-	models.DB.Where("created_by = ?", userId).Find(&purchases).Limit(limitInt)
+	models.DB.Preload("Items").Where("created_by = ?", userId).Order("created_at DESC").Limit(limitInt).Find(&purchases)
 
 	c.Header("Content-Type", "application/json")
 	c.JSON(http.StatusOK, gin.H{"data": purchases})
@@ -158,7 +327,7 @@ func FindPurchases(c *gin.Context) {
 // FindPurchase godoc
 //
 //	@Summary		Find purchase
-//	@Description	find purchase - only returns purchases of the currently logged-in user
+//	@Description	find purchase including its status history - only returns purchases of the currently logged-in user
 //	@Tags			purchases
 //	@Accept			json
 //	@Produce		json
@@ -177,7 +346,9 @@ func FindPurchase(c *gin.Context) {
 	userClaims := jwt.ExtractClaims(c)
 	userId := uuid.MustParse(userClaims["userId"].(string))
 
-	if err := models.DB.Where("created_by = ?", userId).Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
+	if err := models.DB.Preload("Items").Preload("StatusHistory", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("created_by = ?", userId).Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
@@ -186,6 +357,68 @@ func FindPurchase(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": purchase})
 }
 
+type UpdatePurchaseStatusInput struct {
+	Status sumupmodels.TransactionFullStatus `json:"status" binding:"required,oneof=successful failed cancelled"`
+	Note   string                            `json:"note"`
+}
+
+// UpdatePurchaseStatus godoc
+//
+//	@Summary		Update purchase status
+//	@Description	resolves a pending purchase by hand, e.g. if the payment provider never reported its outcome - successful purchases can only be undone with a void
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Purchase
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		409	"invalid transaction status transition"
+//	@Failure		500
+//
+//	@Param			id		path	string						true	"Purchase UUID"
+//	@Param			status	body	UpdatePurchaseStatusInput	true	"New status"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/purchases/{id}/status [patch]
+func UpdatePurchaseStatus(c *gin.Context) {
+	var purchase models.Purchase
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+
+	var input UpdatePurchaseStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
+			return &purchaseError{http.StatusNotFound, err}
+		}
+		if err := models.TransitionPurchase(tx, &purchase, input.Status, models.PurchaseStatusSourceAdmin, userId, input.Note); err != nil {
+			if errors.Is(err, models.ErrInvalidTransition) {
+				return &purchaseError{http.StatusConflict, err}
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		var pErr *purchaseError
+		if errors.As(err, &pErr) {
+			c.AbortWithStatusJSON(pErr.Status, gin.H{"error": pErr.Err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("status of purchase %s set to %s by %s\n", purchase.PurchaseId, purchase.TransactionStatus, userId)
+	paymentv1.SendTransactionUpdate(&purchase)
+	c.JSON(http.StatusOK, gin.H{"data": purchase})
+}
+
 /*type UpdatePurchaseInput struct {
 	Items       []models.Item `json:"items" binding:"required"`
 	PaymentType string        `json:"payment_type" binding:"required"`
@@ -221,15 +454,4 @@ func UpdatePurchase(c *gin.Context) {
 
 	models.DB.Model(&purchase).Updates(&updatedPurchase)
 	c.JSON(http.StatusOK, gin.H{"data": purchase})
-}
-
-func DeletePurchase(c *gin.Context) {
-	var purchase models.Purchase
-	if err := models.DB.Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
-		return
-	}
-
-	models.DB.Delete(&purchase)
-	c.JSON(http.StatusOK, gin.H{"data": "success"})
 }*/
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"metalab/metadrinks/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var connectTestDatabase sync.Once
+
+// setupTestDatabase connects to the database configured with the DB_* variables. The tests create and delete their
+// own rows, but should still only be run against a scratch database.
+func setupTestDatabase(t *testing.T) {
+	t.Helper()
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST is not set, skipping database test")
+	}
+	connectTestDatabase.Do(models.ConnectDatabase)
+}
+
+func TestConcurrentBalancePurchases(t *testing.T) {
+	setupTestDatabase(t)
+	t.Setenv("PIN_REQUIRED_ABOVE", "")
+
+	const (
+		price     = 100
+		balance   = 1000
+		purchases = 15 // more than the balance covers
+	)
+
+	creditLimit := 0
+	user := models.User{Name: "test-" + uuid.NewString(), CreditLimit: &creditLimit, IsActive: true, UsedAt: time.Now()}
+	if err := models.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	item := models.Item{Name: "test-" + uuid.NewString(), Price: price, Stock: purchases}
+	if err := models.DB.Create(&item).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		purchaseIds := models.DB.Model(&models.Purchase{}).Select("purchase_id").Where("created_by = ?", user.UserID)
+		models.DB.Where("purchase_id IN (?)", purchaseIds).Delete(&models.PurchaseStatusChange{})
+		models.DB.Where("purchase_id IN (?)", purchaseIds).Delete(&models.PurchaseItem{})
+		models.DB.Where("item_id = ?", item.ItemId).Delete(&models.StockMovement{})
+		models.DB.Where("user_id = ?", user.UserID).Delete(&models.BalanceTransaction{})
+		models.DB.Where("created_by = ?", user.UserID).Delete(&models.Purchase{})
+		models.DB.Delete(&item)
+		models.DB.Unscoped().Delete(&user)
+	})
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		return models.ChangeBalance(tx, &models.BalanceTransaction{UserId: user.UserID, Type: models.BalanceTransactionTypeCorrection, Amount: balance, Note: "test balance", CreatedBy: user.UserID})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := CreatePurchaseInput{Items: []PurchaseItemInput{{ItemId: item.ItemId, Quantity: 1}}, PaymentType: models.PaymentTypeBalance}
+	errs := make([]error, purchases)
+	var wg sync.WaitGroup
+	for i := range purchases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = createPurchase(user.UserID, input)
+		}()
+	}
+	wg.Wait()
+
+	successful := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			successful++
+		case !errors.Is(err, errNotEnoughBalance):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if successful != balance/price {
+		t.Errorf("expected %d successful purchases, got %d", balance/price, successful)
+	}
+
+	if _, _, err := createPurchase(user.UserID, input); !errors.Is(err, errNotEnoughBalance) {
+		t.Errorf("expected a purchase that overdraws the balance to be rejected, got %v", err)
+	}
+
+	if err := models.DB.Where("user_id = ?", user.UserID).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Balance != 0 {
+		t.Errorf("expected balance 0, got %d", user.Balance)
+	}
+
+	var entries []models.BalanceTransaction
+	models.DB.Where("user_id = ? AND type = ?", user.UserID, models.BalanceTransactionTypePurchase).Find(&entries)
+	if len(entries) != successful {
+		t.Errorf("expected %d ledger entries for purchases, got %d", successful, len(entries))
+	}
+
+	var ledgerSum int
+	models.DB.Model(&models.BalanceTransaction{}).Select("COALESCE(SUM(amount), 0)").Where("user_id = ?", user.UserID).Scan(&ledgerSum)
+	if ledgerSum != user.Balance {
+		t.Errorf("ledger sum %d does not match balance %d", ledgerSum, user.Balance)
+	}
+}
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SalesReportLine struct {
+	ItemId      uuid.UUID          `json:"item_id"`
+	Name        string             `json:"name"`
+	PaymentType models.PaymentType `json:"payment_type"`
+	Quantity    uint               `json:"quantity"`
+	Total       uint               `json:"total"`
+}
+
+// ExportSalesReport godoc
+//
+//	@Summary		Export sales report
+//	@Description	sums up the sold quantity and revenue of every item per payment type for successful purchases in the given period - format=csv returns a csv file instead of json
+//	@Tags			reports
+//	@Accept			json
+//	@Produce		json
+//	@Produce		text/csv
+//	@Success		200	{object}	[]SalesReportLine
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403
+//	@Failure		500
+//
+//	@Param			from	query	string	false	"Start of the period (RFC 3339 or YYYY-MM-DD), defaults to 30 days ago"
+//	@Param			to		query	string	false	"End of the period (RFC 3339 or YYYY-MM-DD), defaults to now"
+//	@Param			format	query	string	false	"Output format"	Enums(json, csv)
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/reports/sales [get]
+func ExportSalesReport(c *gin.Context) {
+	from, err := parseReportTime(c.Query("from"), time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseReportTime(c.Query("to"), time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var lines []SalesReportLine
+	err = models.DB.Model(&models.PurchaseItem{}).
+		Select("purchase_items.item_id, purchase_items.name, purchases.payment_type, SUM(purchase_items.quantity) AS quantity, SUM(purchase_items.line_total) AS total").
+		Joins("JOIN purchases ON purchases.purchase_id = purchase_items.purchase_id").
+		Where("purchases.transaction_status = ? AND purchases.created_at >= ? AND purchases.created_at < ?", sumupmodels.TransactionFullStatusSuccessful, from, to).
+		Group("purchase_items.item_id, purchase_items.name, purchases.payment_type").
+		Order("purchase_items.name ASC, purchases.payment_type ASC").
+		Scan(&lines).Error
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, gin.H{"data": lines})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=sales-"+from.Format("2006-01-02")+"-"+to.Format("2006-01-02")+".csv")
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"item_id", "name", "payment_type", "quantity", "total"})
+	for _, v := range lines {
+		writer.Write([]string{v.ItemId.String(), v.Name, string(v.PaymentType), strconv.FormatUint(uint64(v.Quantity), 10), strconv.FormatUint(uint64(v.Total), 10)})
+	}
+	writer.Flush()
+}
+
+// parseReportTime parses RFC 3339 timestamps and plain dates, returning fallback for empty values.
+func parseReportTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
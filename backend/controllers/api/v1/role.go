@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"metalab/metadrinks/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RoleInput struct {
+	Name        string              `json:"name" binding:"required"`
+	Description string              `json:"description"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// validatePermissions returns an error for the first permission which does not exist.
+func validatePermissions(permissions []models.Permission) error {
+	for _, v := range permissions {
+		if !slices.Contains(models.Permissions, v) {
+			return fmt.Errorf("unknown permission %q", v)
+		}
+	}
+	return nil
+}
+
+// FindRoles godoc
+//
+//	@Summary		Find roles
+//	@Description	lists all roles with their permissions
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Role
+//	@Failure		401
+//	@Failure		403
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/roles [get]
+func FindRoles(c *gin.Context) {
+	var roles []models.Role
+	models.DB.Order("name ASC").Find(&roles)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+// CreateRole godoc
+//
+//	@Summary		Create role
+//	@Description	creates a role with the given permissions
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Role
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403
+//	@Failure		500
+//
+//	@Param			role	body	RoleInput	true	"Create role"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/roles [post]
+func CreateRole(c *gin.Context) {
+	var input RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePermissions(input.Permissions); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{Name: input.Name, Description: input.Description, Permissions: input.Permissions}
+	if err := models.DB.Create(&role).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": role})
+}
+
+// UpdateRole godoc
+//
+//	@Summary		Update role
+//	@Description	replaces the name, description and permissions of a role
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Role
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id		path	string		true	"Role UUID"
+//	@Param			role	body	RoleInput	true	"Update role"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/roles/{id} [put]
+func UpdateRole(c *gin.Context) {
+	var role models.Role
+	if err := models.DB.Where("role_id = ?", c.Param("id")).First(&role).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	var input RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePermissions(input.Permissions); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role.Name = input.Name
+	role.Description = input.Description
+	role.Permissions = input.Permissions
+	if err := models.DB.Save(&role).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": role})
+}
+
+// DeleteRole godoc
+//
+//	@Summary		Delete role
+//	@Description	deletes a role and removes it from all users
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		401
+//	@Failure		403
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Role UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/roles/{id} [delete]
+func DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := models.DB.Where("role_id = ?", c.Param("id")).First(&role).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", role.RoleId).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+type SetUserRolesInput struct {
+	RoleIds []uuid.UUID `json:"role_ids"`
+}
+
+// SetUserRoles godoc
+//
+//	@Summary		Set user roles
+//	@Description	replaces the roles of a user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id		path	string				true	"User UUID"
+//	@Param			roles	body	SetUserRolesInput	true	"Roles"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id}/roles [put]
+func SetUserRoles(c *gin.Context) {
+	var user models.User
+	if err := models.DB.Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	var input SetUserRolesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var roles []models.Role
+	if len(input.RoleIds) != 0 {
+		models.DB.Where("role_id IN ?", input.RoleIds).Find(&roles)
+	}
+	if len(roles) != len(input.RoleIds) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown role"})
+		return
+	}
+
+	if err := models.DB.Model(&user).Association("Roles").Replace(roles); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user.Roles = roles
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	paymentv1 "metalab/metadrinks/controllers/payment/v1"
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutOfStockPolicy decides what happens when a purchase contains more units of an item than are in stock.
+//
+// Possible values:
+//
+// - `warn`: The purchase is created and a warning is returned. The stock level becomes negative.
+// - `refuse`: The purchase is refused.
+type OutOfStockPolicy string
+
+const (
+	OutOfStockPolicyWarn   OutOfStockPolicy = "warn"
+	OutOfStockPolicyRefuse OutOfStockPolicy = "refuse"
+)
+
+// GetOutOfStockPolicy returns the policy set in OUT_OF_STOCK_POLICY, defaulting to warn.
+func GetOutOfStockPolicy() OutOfStockPolicy {
+	if OutOfStockPolicy(os.Getenv("OUT_OF_STOCK_POLICY")) == OutOfStockPolicyRefuse {
+		return OutOfStockPolicyRefuse
+	}
+	return OutOfStockPolicyWarn
+}
+
+type CreateStockMovementInput struct {
+	Change int                        `json:"change" binding:"required"`
+	Reason models.StockMovementReason `json:"reason" binding:"required,oneof=restock breakage correction"`
+	Note   string                     `json:"note"`
+}
+
+// CreateStockMovement godoc
+//
+//	@Summary		Create stock movement
+//	@Description	changes the stock level of an item - restocks must be positive, breakages negative
+//	@Tags			items
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.StockMovement
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id			path	string						true	"Item UUID"
+//	@Param			movement	body	CreateStockMovementInput	true	"Create stock movement"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/items/{id}/stock [post]
+func CreateStockMovement(c *gin.Context) {
+	var item models.Item
+	if err := models.DB.Where("item_id = ?", c.Param("id")).First(&item).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	var input CreateStockMovementInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Reason == models.StockMovementReasonRestock && input.Change < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "restocked amount must be positive"})
+		return
+	}
+	if input.Reason == models.StockMovementReasonBreakage && input.Change > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "broken amount must be negative"})
+		return
+	}
+
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	movement := models.StockMovement{ItemId: item.ItemId, Change: input.Change, Reason: input.Reason, Note: input.Note, CreatedBy: userId}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		return models.AdjustStock(tx, &movement, true)
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	checkLowStock(movement)
+
+	c.JSON(http.StatusOK, gin.H{"data": movement})
+}
+
+// FindStockMovements godoc
+//
+//	@Summary		Find stock movements
+//	@Description	lists the stock movements of an item, newest first
+//	@Tags			items
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.StockMovement
+//	@Failure		400
+//	@Failure		401
+//	@Failure		500
+//
+//	@Param			id		path	string	true	"Item UUID"
+//	@Param			limit	query	int		false	"Maximum number of movements"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/items/{id}/stock [get]
+func FindStockMovements(c *gin.Context) {
+	var movements []models.StockMovement
+
+	limit := c.DefaultQuery("limit", "-1")
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	models.DB.Where("item_id = ?", c.Param("id")).Order("created_at DESC").Limit(limitInt).Find(&movements)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": movements})
+}
+
+// FindLowStockItems godoc
+//
+//	@Summary		Find low stock items
+//	@Description	lists all items whose stock is below their low stock threshold, lowest stock first
+//	@Tags			items
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Item
+//	@Failure		500
+//
+//	@Router			/items/low-stock [get]
+func FindLowStockItems(c *gin.Context) {
+	var items []models.Item
+	models.DB.Where("low_stock_threshold > 0 AND stock < low_stock_threshold").Order("stock ASC").Find(&items)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// sellPurchaseItems removes the items of a purchase from stock. If the OutOfStockPolicy is refuse, items that are
+// not in stock return models.ErrOutOfStock.
+func sellPurchaseItems(tx *gorm.DB, purchase *models.Purchase) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	policy := GetOutOfStockPolicy()
+
+	for _, v := range purchase.Items {
+		movement := models.StockMovement{ItemId: v.ItemId, Change: -int(v.Quantity), Reason: models.StockMovementReasonSale, PurchaseId: &purchase.PurchaseId, CreatedBy: purchase.CreatedBy}
+		if err := models.AdjustStock(tx, &movement, policy != OutOfStockPolicyRefuse); err != nil {
+			if errors.Is(err, models.ErrOutOfStock) {
+				return nil, fmt.Errorf("%s: %w", v.Name, err)
+			}
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, nil
+}
+
+// outOfStockWarnings returns a warning for every purchase item whose sale left a negative stock level.
+func outOfStockWarnings(purchase *models.Purchase, movements []models.StockMovement) []string {
+	var warnings []string
+	for i, v := range movements {
+		if v.StockAfter < 0 {
+			warnings = append(warnings, fmt.Sprintf("%s is out of stock", purchase.Items[i].Name))
+		}
+	}
+	return warnings
+}
+
+// checkLowStock sends a stock alert over the event stream and to the outbound webhooks if the movement made the
+// stock of the item drop below its low stock threshold. Call it only after the movement was committed.
+func checkLowStock(movement models.StockMovement) {
+	item, err := FindItemById(movement.ItemId)
+	if err != nil || item.LowStockThreshold <= 0 {
+		return
+	}
+	if movement.StockAfter >= item.LowStockThreshold || movement.StockAfter-movement.Change < item.LowStockThreshold {
+		return // not low on stock, or already was before this movement
+	}
+
+	payload := paymentv1.SSENotificationStockAlertPayload{ItemId: item.ItemId, Name: item.Name, Stock: movement.StockAfter, LowStockThreshold: item.LowStockThreshold}
+	notification := paymentv1.SSENotification{
+		NotificationType: paymentv1.SSENotificationType(paymentv1.SSENotificationStockAlert),
+		NotificationData: paymentv1.SSENotificationPayload{StockAlertPayload: &payload},
+	}
+	if err := paymentv1.Stream.SendNotification(notification); err != nil {
+		fmt.Printf("error marshalling notification: %s\n", err.Error())
+	}
+	libs.SendOutboundWebhooks("LOW_STOCK_WEBHOOK_URLS", paymentv1.SSENotificationStockAlert, payload)
+}
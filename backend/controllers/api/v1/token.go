@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateUserTokenInput struct {
+	Uid  string `json:"uid" binding:"required"`
+	Name string `json:"name"`
+}
+
+// CreateUserToken godoc
+//
+//	@Summary		Register token
+//	@Description	registers an NFC/RFID tag for the logged-in user - only a hash of the uid is stored
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.UserToken
+//	@Failure		400
+//	@Failure		401
+//	@Failure		409	"token is already registered"
+//	@Failure		500
+//
+//	@Param			token	body	CreateUserTokenInput	true	"Register token"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/tokens [post]
+func CreateUserToken(c *gin.Context) {
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if userId == uuid.Nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the guest user cannot have tokens"})
+		return
+	}
+
+	var input CreateUserTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := models.UserToken{UserId: userId, UidHash: models.HashTokenUid(input.Uid), Name: input.Name}
+	var count int64
+	models.DB.Model(&models.UserToken{}).Where("uid_hash = ?", token.UidHash).Count(&count)
+	if count != 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "token is already registered"})
+		return
+	}
+
+	if err := models.DB.Create(&token).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": token})
+}
+
+// FindUserTokens godoc
+//
+//	@Summary		Find tokens
+//	@Description	lists the NFC/RFID tags of the logged-in user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.UserToken
+//	@Failure		401
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/tokens [get]
+func FindUserTokens(c *gin.Context) {
+	var tokens []models.UserToken
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+
+	models.DB.Where("user_id = ?", userId).Order("created_at ASC").Find(&tokens)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// DeleteUserToken godoc
+//
+//	@Summary		Delete token
+//	@Description	removes an NFC/RFID tag of the logged-in user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Token UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/me/tokens/{id} [delete]
+func DeleteUserToken(c *gin.Context) {
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+
+	result := models.DB.Where("user_token_id = ? AND user_id = ?", c.Param("id"), userId).Delete(&models.UserToken{})
+	if result.Error != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+type TapPurchaseInput struct {
+	Uid   string              `json:"uid" binding:"required"`
+	Items []PurchaseItemInput `json:"items" binding:"required,min=1,dive"`
+	Pin   string              `json:"pin,omitempty"`
+}
+
+// CreateTapPurchase godoc
+//
+//	@Summary		Tap and buy
+//	@Description	creates a purchase paid with the balance of the user the tapped NFC/RFID tag belongs to, without logging in - only possible from registered kiosks
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Purchase
+//	@Failure		400
+//	@Failure		401	"unknown token"
+//	@Failure		401	"valid device credential required"
+//	@Failure		403	"user is restricted"
+//	@Failure		403	"not enough balance"
+//	@Failure		403	"wrong pin"
+//	@Failure		404	"item not found"
+//	@Failure		409	"item is out of stock"
+//	@Failure		429	"too many failed login attempts"
+//	@Failure		500
+//
+//	@Param			purchase	body	TapPurchaseInput	true	"Tap and buy"
+//
+//	@Router			/purchases/tap [post]
+func CreateTapPurchase(c *gin.Context) {
+	var input TapPurchaseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := auth.AuthenticateToken(input.Uid, c.ClientIP(), "tap purchase")
+	if err != nil {
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchase, movements, err := createPurchase(user.UserID, CreatePurchaseInput{Items: input.Items, PaymentType: models.PaymentTypeBalance, Pin: input.Pin})
+	if err != nil {
+		var pErr *purchaseError
+		if errors.As(err, &pErr) {
+			c.AbortWithStatusJSON(pErr.Status, gin.H{"error": pErr.Err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if warnings := outOfStockWarnings(purchase, movements); len(warnings) != 0 {
+		c.JSON(http.StatusOK, gin.H{"data": purchase, "warnings": warnings})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": purchase})
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"metalab/metadrinks/controllers/auth"
+	paymentv1 "metalab/metadrinks/controllers/payment/v1"
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindPendingTopUps godoc
+//
+//	@Summary		Find pending top-ups
+//	@Description	lists the cash top-ups of all users which still have to be confirmed, oldest first
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Purchase
+//	@Failure		401
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/purchases/top-ups [get]
+func FindPendingTopUps(c *gin.Context) {
+	var purchases []models.Purchase
+	models.DB.Where("payment_type = ? AND transaction_status = ? AND refund_amount > 0", models.PaymentTypeCash, sumupmodels.TransactionFullStatusPending).Order("created_at ASC").Find(&purchases)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": purchases})
+}
+
+// ConfirmTopUp godoc
+//
+//	@Summary		Confirm top-up
+//	@Description	confirms that the cash of a pending cash top-up was received and credits the balance of the user - trusted users and users with the balances.manage permission can confirm top-ups, but only the latter their own
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Purchase
+//	@Failure		401
+//	@Failure		403	"top-ups cannot be confirmed by the same user"
+//	@Failure		404
+//	@Failure		409	"only pending cash top-ups can be confirmed"
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Purchase UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/purchases/{id}/confirm [post]
+func ConfirmTopUp(c *gin.Context) {
+	var purchase models.Purchase
+	userId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	canConfirmOwn := auth.CurrentUser(c).HasPermission(models.PermissionBalancesManage) // loaded per request, not from the claims
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
+			return &purchaseError{http.StatusNotFound, err}
+		}
+		if purchase.PaymentType != models.PaymentTypeCash || purchase.TopUpAmount == 0 || purchase.TransactionStatus != sumupmodels.TransactionFullStatusPending {
+			return &purchaseError{http.StatusConflict, fmt.Errorf("only pending cash top-ups can be confirmed")}
+		}
+		if purchase.CreatedBy == userId && !canConfirmOwn {
+			return &purchaseError{http.StatusForbidden, fmt.Errorf("top-ups cannot be confirmed by the same user")}
+		}
+		return models.TransitionPurchase(tx, &purchase, sumupmodels.TransactionFullStatusSuccessful, models.PurchaseStatusSourceConfirmation, userId, "cash received")
+	})
+	if err != nil {
+		var pErr *purchaseError
+		if errors.As(err, &pErr) {
+			c.AbortWithStatusJSON(pErr.Status, gin.H{"error": pErr.Err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("top-up %s of %d confirmed by %s\n", purchase.PurchaseId, purchase.TopUpAmount, userId)
+	paymentv1.SendTransactionUpdate(&purchase)
+	c.JSON(http.StatusOK, gin.H{"data": purchase})
+}
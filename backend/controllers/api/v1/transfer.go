@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	paymentv1 "metalab/metadrinks/controllers/payment/v1"
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TransferBalanceInput struct {
+	RecipientId uuid.UUID `json:"recipient_id" binding:"required"`
+	Amount      uint      `json:"amount" binding:"required,min=1"`
+	Note        string    `json:"note"`
+}
+
+// TransferBalance godoc
+//
+//	@Summary		Transfer balance
+//	@Description	moves balance from the logged-in user to another user - both users get a transfer purchase in their purchase history and the recipient is notified over the event stream
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Purchase
+//	@Failure		400	"cannot transfer balance to yourself"
+//	@Failure		401
+//	@Failure		403	"user is inactive"
+//	@Failure		403	"recipient is inactive"
+//	@Failure		403	"user is restricted"
+//	@Failure		403	"recipient is restricted"
+//	@Failure		403	"not enough balance"
+//	@Failure		404	"recipient not found"
+//	@Failure		500
+//
+//	@Param			transfer	body	TransferBalanceInput	true	"Transfer balance"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/transfer [post]
+func TransferBalance(c *gin.Context) {
+	var input TransferBalanceInput
+	senderId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sent, sender, err := transferBalance(senderId, input)
+	if err != nil {
+		var pErr *purchaseError
+		if errors.As(err, &pErr) {
+			c.AbortWithStatusJSON(pErr.Status, gin.H{"error": pErr.Err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	notification := paymentv1.SSENotification{
+		NotificationType: paymentv1.SSENotificationType(paymentv1.SSENotificationBalanceTransfer),
+		NotificationData: paymentv1.SSENotificationPayload{TransferPayload: &paymentv1.SSENotificationBalanceTransferPayload{
+			SenderId:    senderId,
+			SenderName:  sender.Name,
+			RecipientId: input.RecipientId,
+			Amount:      input.Amount,
+			Note:        input.Note,
+		}},
+	}
+	if err := paymentv1.Stream.SendNotification(notification); err != nil {
+		fmt.Printf("error marshalling notification: %s\n", err.Error())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sent})
+}
+
+// transferBalance moves the amount from the sender to the recipient in a single transaction. Both user rows are locked
+// in the order of their ids, so concurrent transfers between the same users cannot deadlock. It returns the transfer
+// purchase of the sender and the sender.
+func transferBalance(senderId uuid.UUID, input TransferBalanceInput) (*models.Purchase, *models.User, error) {
+	if senderId == uuid.Nil {
+		return nil, nil, &purchaseError{http.StatusForbidden, fmt.Errorf("guests cannot transfer balance")}
+	}
+	if input.RecipientId == senderId {
+		return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("cannot transfer balance to yourself")}
+	}
+	if input.RecipientId == uuid.Nil {
+		return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("cannot transfer balance to guests")}
+	}
+	if input.Amount >= math.MaxInt32 {
+		return nil, nil, &purchaseError{http.StatusBadRequest, fmt.Errorf("amount exceeds maximum allowed value")}
+	}
+
+	var sent models.Purchase
+	var sender *models.User
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		users := make(map[uuid.UUID]*models.User)
+		lockOrder := []uuid.UUID{senderId, input.RecipientId}
+		if senderId.String() > input.RecipientId.String() {
+			lockOrder = []uuid.UUID{input.RecipientId, senderId}
+		}
+		for _, v := range lockOrder {
+			user, err := GetUserForUpdate(tx, v)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) && v == input.RecipientId {
+					return &purchaseError{http.StatusNotFound, fmt.Errorf("recipient not found")}
+				}
+				return err
+			}
+			users[v] = user
+		}
+		sender = users[senderId]
+		recipient := users[input.RecipientId]
+
+		if !sender.IsActive {
+			return &purchaseError{http.StatusForbidden, errUserInactive}
+		}
+		if !recipient.IsActive {
+			return &purchaseError{http.StatusForbidden, fmt.Errorf("recipient is inactive")}
+		}
+		if sender.IsRestricted {
+			return &purchaseError{http.StatusForbidden, errUserRestricted}
+		}
+		if recipient.IsRestricted {
+			return &purchaseError{http.StatusForbidden, fmt.Errorf("recipient is restricted")}
+		}
+		if sender.Balance-int(input.Amount) < -sender.CalculateCreditLimit() {
+			return &purchaseError{http.StatusForbidden, errNotEnoughBalance}
+		}
+
+		sent = models.Purchase{PaymentType: models.PaymentTypeTransfer, TransactionStatus: sumupmodels.TransactionFullStatusSuccessful, FinalCost: input.Amount, CreatedBy: senderId, CounterpartyId: &recipient.UserID}
+		received := models.Purchase{PaymentType: models.PaymentTypeTransfer, TransactionStatus: sumupmodels.TransactionFullStatusSuccessful, TopUpAmount: input.Amount, CreatedBy: recipient.UserID, CounterpartyId: &senderId}
+		if err := tx.Create(&sent).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&received).Error; err != nil {
+			return err
+		}
+
+		if err := models.ChangeBalance(tx, &models.BalanceTransaction{UserId: senderId, Type: models.BalanceTransactionTypeTransfer, Amount: -int(input.Amount), PurchaseId: &sent.PurchaseId, CounterpartyId: &recipient.UserID, Note: input.Note, CreatedBy: senderId}); err != nil {
+			return err
+		}
+		return models.ChangeBalance(tx, &models.BalanceTransaction{UserId: recipient.UserID, Type: models.BalanceTransactionTypeTransfer, Amount: int(input.Amount), PurchaseId: &received.PurchaseId, CounterpartyId: &senderId, Note: input.Note, CreatedBy: senderId})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Printf("%s transferred %d to %s\n", senderId, input.Amount, input.RecipientId)
+	return &sent, sender, nil
+}
@@ -2,14 +2,19 @@ package v1
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
+	"metalab/metadrinks/controllers/auth"
 	"metalab/metadrinks/models"
 
+	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CreateUserInput struct {
@@ -24,7 +29,7 @@ type CreateUserInput struct {
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	models.User
+//	@Success		200	{object}	models.PrivateUser
 //	@Failure		400
 //	@Failure		500
 //
@@ -46,43 +51,74 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 	user := models.User{UserID: userId, Name: input.Name, Password: string(hashedPassword), UsedAt: time.Now().Local()}
-	models.DB.Create(&user)
+	if err := models.DB.Create(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
 }
 
 // FindUsers godoc
 //
 //	@Summary		Find users
-//	@Description	Lists all users
+//	@Description	lists the id, name and image of all active users, most recently used first
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	[]models.User
+//	@Success		200	{object}	[]models.PublicUser
 //	@Failure		500
 //
-//
 //	@Router			/users [get]
 func FindUsers(c *gin.Context) {
-	var users []map[string]interface{}
-	models.DB.Model(&models.User{}).Find(&users).Order("used_at DESC")
+	var users []models.User
+	models.DB.Where("is_active = ?", true).Order("used_at DESC").Find(&users)
+
+	publicUsers := make([]models.PublicUser, len(users))
+	for i := range users {
+		publicUsers[i] = users[i].Public()
+	}
 
-	for _, user := range users { // do not return the user password
-		delete(user, "password")
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": publicUsers})
+}
+
+// FindUserDetails godoc
+//
+//	@Summary		Find user details
+//	@Description	lists all details of all users, including inactive ones
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.PrivateUser
+//	@Failure		401
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/details [get]
+func FindUserDetails(c *gin.Context) {
+	var users []models.User
+	models.DB.Order("name ASC").Find(&users)
+
+	privateUsers := make([]models.PrivateUser, len(users))
+	for i := range users {
+		privateUsers[i] = users[i].Private()
 	}
 
 	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, gin.H{"data": users})
+	c.JSON(http.StatusOK, gin.H{"data": privateUsers})
 }
 
 // FindUser godoc
 //
 //	@Summary		Find user
-//	@Description	Returns specific user
+//	@Description	returns specific user - all details are only returned to the user themselves and to users with the users.manage permission, everyone else gets id, name and image
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	models.User
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		404
 //	@Failure		500
 //
 //	@Param			id	path	string	true	"User UUID"
@@ -96,17 +132,47 @@ func FindUser(c *gin.Context) {
 		return
 	}
 
-	user.Password = ""
 	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	if caller := auth.OptionalUser(c); caller != nil && (caller.UserID == user.UserID || caller.HasPermission(models.PermissionUsersManage)) {
+		c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": user.Public()})
 }
 
 type UpdateUserInput struct {
-	Name string `json:"name" binding:"required"`
+	Name         *string `json:"name,omitempty"`
+	Image        *string `json:"image,omitempty"`
+	IsTrusted    *bool   `json:"is_trusted,omitempty"`
+	IsAdmin      *bool   `json:"is_admin,omitempty"`
+	IsActive     *bool   `json:"is_active,omitempty"`
+	IsRestricted *bool   `json:"is_restricted,omitempty"`
+	OidcSubject  *string `json:"oidc_subject,omitempty"` // links the user to an account at the identity provider, empty unlinks it
 }
 
-/*func UpdateUser(c *gin.Context) {
-	var user models.Item
+// UpdateUser godoc
+//
+//	@Summary		Update user
+//	@Description	updates the name, image, flags and single sign-on link of a user - only the given fields are changed. Admins cannot remove their own admin flag or deactivate themselves.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403	"only admins can change the admin flag"
+//	@Failure		403	"only admins can change the single sign-on link"
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id		path	string			true	"User UUID"
+//	@Param			user	body	UpdateUserInput	true	"Update user"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id} [patch]
+func UpdateUser(c *gin.Context) {
+	var user models.User
 	if err := models.DB.Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
 		return
@@ -118,12 +184,87 @@ type UpdateUserInput struct {
 		return
 	}
 
-	updatedUser := models.User{Name: input.Name}
+	adminId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if user.UserID == adminId && ((input.IsAdmin != nil && !*input.IsAdmin) || (input.IsActive != nil && !*input.IsActive)) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "you cannot remove your own admin flag or deactivate yourself"})
+		return
+	}
 
-	models.DB.Model(&user).Updates(&updatedUser)
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	if input.IsAdmin != nil && !auth.CurrentUser(c).IsAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only admins can change the admin flag"})
+		return
+	}
+	// whoever controls the linked account can log in as the user, so linking is as privileged as the admin flag
+	if input.OidcSubject != nil && !auth.CurrentUser(c).IsAdmin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only admins can change the single sign-on link"})
+		return
+	}
+
+	updatedUser := map[string]any{}
+	if input.Name != nil {
+		if *input.Name == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		updatedUser["name"] = *input.Name
+	}
+	if input.Image != nil {
+		updatedUser["image"] = *input.Image
+	}
+	if input.IsTrusted != nil {
+		updatedUser["is_trusted"] = *input.IsTrusted
+	}
+	if input.IsAdmin != nil {
+		updatedUser["is_admin"] = *input.IsAdmin
+	}
+	if input.IsActive != nil {
+		updatedUser["is_active"] = *input.IsActive
+	}
+	if input.IsRestricted != nil {
+		updatedUser["is_restricted"] = *input.IsRestricted
+	}
+	if input.OidcSubject != nil {
+		if *input.OidcSubject == "" {
+			updatedUser["oidc_subject"] = nil
+		} else {
+			updatedUser["oidc_subject"] = *input.OidcSubject
+		}
+	}
+
+	if err := models.DB.Model(&user).Updates(updatedUser).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user.EffectiveCreditLimit = user.CalculateCreditLimit()
+
+	if input.IsActive != nil && !*input.IsActive {
+		if err := auth.RevokeUserSessions(user.UserID); err != nil {
+			fmt.Printf("error while revoking sessions: %s\n", err.Error())
+		}
+	}
+
+	fmt.Printf("user %s updated by %s: %v\n", user.UserID, adminId, updatedUser)
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
 }
 
+// DeleteUser godoc
+//
+//	@Summary		Delete user
+//	@Description	soft-deletes a user - their purchases and ledger are kept and the user can be restored
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{string}	string	"success"
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"User UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id} [delete]
 func DeleteUser(c *gin.Context) {
 	var user models.User
 	if err := models.DB.Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
@@ -131,64 +272,193 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	models.DB.Delete(&user)
+	adminId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	if user.UserID == adminId || user.UserID == uuid.Nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "you cannot delete yourself or the guest user"})
+		return
+	}
+
+	if err := models.DB.Delete(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := auth.RevokeUserSessions(user.UserID); err != nil {
+		fmt.Printf("error while revoking sessions: %s\n", err.Error())
+	}
+
+	fmt.Printf("user %s deleted by %s\n", user.UserID, adminId)
 	c.JSON(http.StatusOK, gin.H{"data": "success"})
-}*/
+}
 
-/*
-	func UpdateUserBalance(c *gin.Context) {
-		var user models.User
-		if err := models.DB.Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
-			return
-		}
+// FindDeletedUsers godoc
+//
+//	@Summary		Find deleted users
+//	@Description	lists all soft-deleted users, most recently deleted first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.PrivateUser
+//	@Failure		401
+//	@Failure		500
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/deleted [get]
+func FindDeletedUsers(c *gin.Context) {
+	var users []models.User
+	models.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&users)
+
+	privateUsers := make([]models.PrivateUser, len(users))
+	for i := range users {
+		privateUsers[i] = users[i].Private()
+	}
 
-		var input struct {
-			Balance int `json:"balance" binding:"required"`
-		}
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": privateUsers})
+}
 
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
+// RestoreUser godoc
+//
+//	@Summary		Restore user
+//	@Description	restores a soft-deleted user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"User UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id}/restore [post]
+func RestoreUser(c *gin.Context) {
+	var user models.User
+	if err := models.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", c.Param("id")).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
 
-		if input.Balance > 0 {
+	if err := models.DB.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user.DeletedAt = gorm.DeletedAt{}
 
-		} else if input.Balance < 0 {
+	fmt.Printf("user %s restored by %s\n", user.UserID, jwt.ExtractClaims(c)["userId"])
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
 
-		} else {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "added balance must not be 0"})
-			return
-		}
+type UpdateUserBalanceInput struct {
+	Change int    `json:"change" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
 
-		user.Balance = user.Balance + input.Balance
-		models.DB.Save(&user)
+// UpdateUserBalance godoc
+//
+//	@Summary		Correct user balance
+//	@Description	adds change to the balance of a user (negative values remove balance) and records it as a correction in the ledger - also works for deleted users
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.BalanceTransaction
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id			path	string					true	"User UUID"
+//	@Param			correction	body	UpdateUserBalanceInput	true	"Balance correction"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id}/balance [post]
+func UpdateUserBalance(c *gin.Context) {
+	var user models.User
+	if err := models.DB.Unscoped().Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
 
-		c.JSON(http.StatusOK, gin.H{"data": user})
+	var input UpdateUserBalanceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Change >= math.MaxInt32 || input.Change <= math.MinInt32 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "change exceeds maximum allowed value"})
+		return
 	}
-*/
 
-func GetUserBalance(userId uuid.UUID) (*int, error) {
+	adminId := uuid.MustParse(jwt.ExtractClaims(c)["userId"].(string))
+	entry := models.BalanceTransaction{UserId: user.UserID, Type: models.BalanceTransactionTypeCorrection, Amount: input.Change, Note: input.Reason, CreatedBy: adminId}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		return models.ChangeBalance(tx, &entry)
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fmt.Printf("balance of user %s corrected by %d by %s: %s\n", user.UserID, input.Change, adminId, input.Reason)
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+type SetCreditLimitInput struct {
+	CreditLimit *int `json:"credit_limit" binding:"omitempty,min=0"`
+}
+
+// SetUserCreditLimit godoc
+//
+//	@Summary		Set user credit limit
+//	@Description	sets how far the balance of a user may go below zero - null resets it to the global default
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.PrivateUser
+//	@Failure		400
+//	@Failure		401
+//	@Failure		404
+//	@Failure		500
+//
+//	@Param			id		path	string				true	"User UUID"
+//	@Param			limit	body	SetCreditLimitInput	true	"Credit limit"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/{id}/credit-limit [put]
+func SetUserCreditLimit(c *gin.Context) {
 	var user models.User
+	if err := models.DB.Where("user_id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
 
-	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
-		return nil, err
+	var input SetCreditLimitInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if user.IsRestricted {
-		return nil, fmt.Errorf("user is restricted")
+	if err := models.DB.Model(&user).Update("credit_limit", input.CreditLimit).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	user.EffectiveCreditLimit = user.CalculateCreditLimit()
 
-	return &user.Balance, nil
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
 }
 
-func UpdateUserBalance(userId uuid.UUID, change int) {
+// GetUserForUpdate loads the user and locks their row until the end of the transaction.
+func GetUserForUpdate(tx *gorm.DB, userId uuid.UUID) (*models.User, error) {
 	var user models.User
 
-	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
-		return
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userId).First(&user).Error; err != nil {
+		return nil, err
 	}
 
-	user.Balance = user.Balance + change
-	models.DB.Save(&user)
+	return &user, nil
 }
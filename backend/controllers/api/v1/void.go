@@ -0,0 +1,250 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"metalab/metadrinks/controllers/auth"
+	paymentv1 "metalab/metadrinks/controllers/payment/v1"
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetVoidWindow returns how long buyers can void their own purchases, set in VOID_WINDOW. Defaults to 5 minutes.
+func GetVoidWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("VOID_WINDOW"))
+	if err != nil {
+		return 5 * time.Minute
+	}
+	return window
+}
+
+type VoidPurchaseInput struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// VoidPurchase godoc
+//
+//	@Summary		Void purchase
+//	@Description	undoes a successful purchase - buyers can void their own purchases within the void window, users with the purchases.manage permission any purchase at any time. Balance payments are credited back, card payments are refunded and cash payments are marked for drawer reconciliation. Card purchases are refund_pending while the refund is sent to the payment provider.
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Purchase
+//	@Failure		400
+//	@Failure		401
+//	@Failure		403	"void window has expired"
+//	@Failure		404
+//	@Failure		409	"only successful purchases can be voided"
+//	@Failure		409	"transfers cannot be voided"
+//	@Failure		500
+//	@Failure		502	"error while refunding transaction"
+//
+//	@Param			id		path	string				true	"Purchase UUID"
+//	@Param			void	body	VoidPurchaseInput	true	"Void purchase"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/purchases/{id}/void [post]
+func VoidPurchase(c *gin.Context) {
+	var purchase models.Purchase
+	userClaims := jwt.ExtractClaims(c)
+	userId := uuid.MustParse(userClaims["userId"].(string))
+	canVoidAny := auth.CurrentUser(c).HasPermission(models.PermissionPurchasesManage)
+
+	var input VoidPurchaseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
+			return &purchaseError{http.StatusNotFound, err}
+		}
+		if !canVoidAny {
+			if purchase.CreatedBy != userId {
+				return &purchaseError{http.StatusNotFound, gorm.ErrRecordNotFound}
+			}
+			if time.Since(purchase.CreatedAt) > GetVoidWindow() {
+				return &purchaseError{http.StatusForbidden, fmt.Errorf("void window has expired")}
+			}
+		}
+		if purchase.PaymentType == models.PaymentTypeTransfer {
+			return &purchaseError{http.StatusConflict, fmt.Errorf("transfers cannot be voided")}
+		}
+		if purchase.TransactionStatus != sumupmodels.TransactionFullStatusSuccessful {
+			return &purchaseError{http.StatusConflict, fmt.Errorf("only successful purchases can be voided")}
+		}
+
+		status := models.TransactionStatusVoided
+		switch purchase.PaymentType {
+		case models.PaymentTypeBalance:
+			if purchase.FinalCost != 0 {
+				if err := models.ChangeBalance(tx, &models.BalanceTransaction{UserId: purchase.CreatedBy, Type: models.BalanceTransactionTypeRefund, Amount: int(purchase.FinalCost), PurchaseId: &purchase.PurchaseId, Note: input.Reason, CreatedBy: userId}); err != nil {
+					return err
+				}
+			}
+		case models.PaymentTypeCard: // refunded after the commit, so the row is not locked while the provider is called
+			status = models.TransactionStatusRefundPending
+		}
+		if purchase.TopUpAmount != 0 { // voided top-ups take the added balance away again
+			if err := models.ChangeBalance(tx, &models.BalanceTransaction{UserId: purchase.CreatedBy, Type: models.BalanceTransactionTypeRefund, Amount: -int(purchase.TopUpAmount), PurchaseId: &purchase.PurchaseId, Note: input.Reason, CreatedBy: userId}); err != nil {
+				return err
+			}
+		}
+		if err := models.TransitionPurchase(tx, &purchase, status, models.PurchaseStatusSourceVoid, userId, input.Reason); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		purchase.VoidedAt = &now
+		purchase.VoidedBy = &userId
+		purchase.VoidReason = input.Reason
+		return tx.Model(&purchase).Select("voided_at", "voided_by", "void_reason").Updates(&purchase).Error
+	})
+	if err != nil {
+		var pErr *purchaseError
+		if errors.As(err, &pErr) {
+			c.AbortWithStatusJSON(pErr.Status, gin.H{"error": pErr.Err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if purchase.TransactionStatus == models.TransactionStatusRefundPending {
+		if err := refundPurchase(&purchase, userId, input.Reason); err != nil {
+			var pErr *purchaseError
+			if errors.As(err, &pErr) {
+				c.AbortWithStatusJSON(pErr.Status, gin.H{"error": pErr.Err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	fmt.Printf("purchase %s voided by %s: %s\n", purchase.PurchaseId, userId, input.Reason)
+	paymentv1.SendTransactionUpdate(&purchase)
+	c.JSON(http.StatusOK, gin.H{"data": purchase})
+}
+
+// refundPurchase refunds a card purchase whose status was committed as refund pending. Because the purchase is no
+// longer successful, it cannot be voided and refunded a second time while the provider is called. If the refund
+// fails, the purchase becomes successful again and a removed top-up is credited back.
+func refundPurchase(purchase *models.Purchase, userId uuid.UUID, reason string) error {
+	refundErr := libs.Provider.Refund(purchase.TransactionId, purchase.ClientTransactionId)
+	if refundErr != nil {
+		fmt.Printf("error while refunding transaction: %s\n", refundErr.Error())
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").Where("purchase_id = ?", purchase.PurchaseId).First(purchase).Error; err != nil {
+			return err
+		}
+		if refundErr == nil {
+			return models.TransitionPurchase(tx, purchase, models.TransactionStatusRefunded, models.PurchaseStatusSourceVoid, userId, reason)
+		}
+
+		if err := models.TransitionPurchase(tx, purchase, sumupmodels.TransactionFullStatusSuccessful, models.PurchaseStatusSourceVoid, userId, "refund failed: "+refundErr.Error()); err != nil {
+			return err
+		}
+		purchase.VoidedAt = nil
+		purchase.VoidedBy = nil
+		purchase.VoidReason = ""
+		return tx.Model(purchase).Select("voided_at", "voided_by", "void_reason").Updates(purchase).Error
+	})
+	if err != nil {
+		// the purchase stays refund pending, so it is neither refunded twice nor treated as paid
+		fmt.Printf("error while recording the refund of purchase %s (refund error: %v): %s\n", purchase.PurchaseId, refundErr, err.Error())
+		return err
+	}
+
+	if refundErr != nil {
+		return &purchaseError{http.StatusBadGateway, refundErr}
+	}
+	return nil
+}
+
+// FindVoidedPurchases godoc
+//
+//	@Summary		Find voided purchases
+//	@Description	lists voided and refunded purchases of all users, newest first - use payment_type=cash&unreconciled=true to get the cash that still has to be taken out of the drawer
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]models.Purchase
+//	@Failure		401
+//	@Failure		500
+//
+//	@Param			payment_type	query	string	false	"Payment type"	Enums(cash, card, balance)
+//	@Param			unreconciled	query	bool	false	"Only return purchases that were not reconciled yet"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/purchases/voids [get]
+func FindVoidedPurchases(c *gin.Context) {
+	var purchases []models.Purchase
+
+	query := models.DB.Preload("Items").Where("voided_at IS NOT NULL")
+	if paymentType := c.Query("payment_type"); paymentType != "" {
+		query = query.Where("payment_type = ?", paymentType)
+	}
+	if c.Query("unreconciled") == "true" {
+		query = query.Where("drawer_reconciled_at IS NULL")
+	}
+	query.Order("voided_at DESC").Find(&purchases)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, gin.H{"data": purchases})
+}
+
+// ReconcileVoidedPurchase godoc
+//
+//	@Summary		Reconcile voided purchase
+//	@Description	marks the cash of a voided cash purchase as taken out of the drawer
+//	@Tags			purchases
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.Purchase
+//	@Failure		401
+//	@Failure		404
+//	@Failure		409
+//	@Failure		500
+//
+//	@Param			id	path	string	true	"Purchase UUID"
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/purchases/{id}/reconcile [post]
+func ReconcileVoidedPurchase(c *gin.Context) {
+	var purchase models.Purchase
+	if err := models.DB.Preload("Items").Where("purchase_id = ?", c.Param("id")).First(&purchase).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if purchase.VoidedAt == nil || purchase.PaymentType != models.PaymentTypeCash {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "only voided cash purchases can be reconciled"})
+		return
+	}
+
+	if purchase.DrawerReconciledAt == nil {
+		now := time.Now()
+		purchase.DrawerReconciledAt = &now
+		models.DB.Model(&purchase).Update("drawer_reconciled_at", now)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": purchase})
+}
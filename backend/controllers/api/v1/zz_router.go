@@ -2,29 +2,87 @@ package v1
 
 import (
 	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RegisterRoutesV1 registers the api routes. Routes which change the shop need the permission passed to
+// auth.RequirePermission, admins have all permissions.
 func RegisterRoutesV1(r *gin.RouterGroup) {
 	i := r.Group("items")
 	i.GET("/", FindItems)
+	i.GET("/low-stock", FindLowStockItems)
 	i.GET("/:id", FindItem)
-	i.POST("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserAdmin(), CreateItem)
-	i.PUT("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserAdmin(), UpdateItem)
-	i.DELETE("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserAdmin(), DeleteItem)
+	i.POST("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), CreateItem)
+	i.PUT("/sort", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), SortItems)
+	i.PUT("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), UpdateItem)
+	i.DELETE("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), DeleteItem)
+	i.GET("/:id/stock", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionStockManage), FindStockMovements)
+	i.POST("/:id/stock", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionStockManage), CreateStockMovement)
+
+	ca := r.Group("categories")
+	ca.GET("/", FindCategories)
+	ca.GET("/:id", FindCategory)
+	ca.POST("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), CreateCategory)
+	ca.PUT("/sort", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), SortCategories)
+	ca.PUT("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), UpdateCategory)
+	ca.DELETE("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionItemsManage), DeleteCategory)
 
 	u := r.Group("users")
 	u.POST("/", CreateUser)
 	u.GET("/", FindUsers)
+	u.POST("/transfer", auth.JWTAuthMiddleware.MiddlewareFunc(), TransferBalance)
+	u.GET("/me", auth.JWTAuthMiddleware.MiddlewareFunc(), FindCurrentUser)
+	u.PATCH("/me", auth.JWTAuthMiddleware.MiddlewareFunc(), UpdateCurrentUser)
+	u.PUT("/me/image", auth.JWTAuthMiddleware.MiddlewareFunc(), UpdateCurrentUserImage)
+	u.PUT("/me/password", auth.JWTAuthMiddleware.MiddlewareFunc(), ChangeCurrentUserPassword)
+	u.PUT("/me/pin", auth.JWTAuthMiddleware.MiddlewareFunc(), SetCurrentUserPin)
+	u.DELETE("/me/pin", auth.JWTAuthMiddleware.MiddlewareFunc(), DeleteCurrentUserPin)
+	u.GET("/me/tokens", auth.JWTAuthMiddleware.MiddlewareFunc(), FindUserTokens)
+	u.POST("/me/tokens", auth.JWTAuthMiddleware.MiddlewareFunc(), CreateUserToken)
+	u.DELETE("/me/tokens/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), DeleteUserToken)
+	u.GET("/details", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), FindUserDetails)
+	u.GET("/deleted", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), FindDeletedUsers)
 	u.GET("/:id", FindUser)
-	//u.PUT("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserAdmin(), UpdateUser)
-	//u.DELETE("//:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserAdmin(), DeleteUser)
+	u.PATCH("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), UpdateUser)
+	u.DELETE("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), DeleteUser)
+	u.POST("/:id/restore", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), RestoreUser)
+	u.POST("/:id/unlock", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), UnlockUser)
+	u.POST("/:id/balance", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionBalancesManage), UpdateUserBalance)
+	u.GET("/:id/ledger", auth.JWTAuthMiddleware.MiddlewareFunc(), FindUserLedger)
+	u.PUT("/:id/roles", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionRolesManage), SetUserRoles)
+	u.PUT("/:id/credit-limit", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionBalancesManage), SetUserCreditLimit)
 
 	p := r.Group("purchases")
-	p.POST("/", auth.JWTAuthMiddleware.MiddlewareFunc(), CreatePurchase)
+	p.POST("/", auth.OptionalDevice(), auth.JWTAuthMiddleware.MiddlewareFunc(), CreatePurchase)
+	p.POST("/tap", auth.RequireDevice(), CreateTapPurchase)
 	p.GET("/", auth.JWTAuthMiddleware.MiddlewareFunc(), FindPurchases)
+	p.GET("/voids", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionPurchasesManage), FindVoidedPurchases)
+	p.GET("/top-ups", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserTrusted(), FindPendingTopUps)
 	p.GET("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), FindPurchase)
 	//p.PATCH("/:id", UpdatePurchase)
-	//p.DELETE("/:id", DeletePurchase)
+	p.PATCH("/:id/status", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionPurchasesManage), UpdatePurchaseStatus)
+	p.POST("/:id/confirm", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.IsUserTrusted(), ConfirmTopUp)
+	p.POST("/:id/void", auth.JWTAuthMiddleware.MiddlewareFunc(), VoidPurchase)
+	p.POST("/:id/reconcile", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionPurchasesManage), ReconcileVoidedPurchase)
+
+	d := r.Group("devices")
+	d.GET("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionDevicesManage), FindDevices)
+	d.GET("/me", auth.RequireDevice(), FindCurrentDevice)
+	d.POST("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionDevicesManage), CreateDevice)
+	d.PATCH("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionDevicesManage), UpdateDevice)
+	d.DELETE("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionDevicesManage), DeleteDevice)
+	d.POST("/:id/credential", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionDevicesManage), RotateDeviceCredential)
+
+	r.GET("/audit-events", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionUsersManage), FindAuditEvents)
+
+	ro := r.Group("roles")
+	ro.GET("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionRolesManage), FindRoles)
+	ro.POST("/", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionRolesManage), CreateRole)
+	ro.PUT("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionRolesManage), UpdateRole)
+	ro.DELETE("/:id", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionRolesManage), DeleteRole)
+
+	re := r.Group("reports")
+	re.GET("/sales", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionReportsExport), ExportSalesReport)
 }
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -35,12 +37,13 @@ func InitParams() *jwt.GinJWTMiddleware {
 		Realm:            "drinks-pos",
 		Key:              []byte(os.Getenv("JWT_SECRET")),
 		SigningAlgorithm: "HS512",
-		Timeout:          time.Minute * 1,
-		MaxRefresh:       time.Minute * 1,
+		Timeout:          GetAccessTokenTTL(),
+		MaxRefresh:       GetAccessTokenTTL(), // sessions are extended with refresh tokens instead
 		// IdentityKey:      identityKey,
 		PayloadFunc: payloadFunc(),
 
 		IdentityHandler: identityHandler(),
+		Authorizator:    authorizator(),
 		Authenticator:   authenticator(),
 		Unauthorized:    unauthorized(),
 		SendCookie:      true,
@@ -54,9 +57,10 @@ func InitParams() *jwt.GinJWTMiddleware {
 
 func payloadFunc() func(data any) jwt.MapClaims {
 	return func(data any) jwt.MapClaims {
-		if v, ok := data.(*models.User); ok {
+		if v, ok := data.(*sessionUser); ok {
 			return jwt.MapClaims{
 				"userId":     v.UserID.String(),
+				"sid":        v.SessionId.String(),
 				"sub":        v.Name,
 				"restricted": v.IsRestricted,
 				"trusted":    v.IsTrusted,
@@ -67,12 +71,43 @@ func payloadFunc() func(data any) jwt.MapClaims {
 	}
 }
 
+// identityHandler loads the user of the token on every request, so deactivated and deleted users and revoked sessions
+// are rejected by authorizator even though their token is still valid.
 func identityHandler() func(c *gin.Context) any {
 	return func(c *gin.Context) any {
 		claims := jwt.ExtractClaims(c)
-		return &models.User{
-			Name: claims["sub"].(string),
+		userId, ok := claims["userId"].(string)
+		if !ok {
+			return nil
 		}
+		if !isSessionActive(claims["sid"]) {
+			return nil
+		}
+
+		var user models.User
+		if err := models.DB.Preload("Roles").Where("user_id = ?", userId).First(&user).Error; err != nil {
+			return nil
+		}
+		return &user
+	}
+}
+
+func isSessionActive(sessionId any) bool {
+	if sessionId == nil {
+		return false
+	}
+
+	var session models.Session
+	if err := models.DB.Where("session_id = ?", sessionId).First(&session).Error; err != nil {
+		return false
+	}
+	return session.IsActive()
+}
+
+func authorizator() func(data any, c *gin.Context) bool {
+	return func(data any, c *gin.Context) bool {
+		user, ok := data.(*models.User)
+		return ok && user.IsActive
 	}
 }
 
@@ -84,12 +119,24 @@ func authenticator() func(c *gin.Context) (any, error) {
 		}
 		username := loginVals.Username
 		password := loginVals.Password
+		keys := []string{usernameKey(username), ipKey(c.ClientIP())}
 
-		user, err := TryAuthenticate(username, password)
+		if err := limiter.check(keys...); err != nil {
+			log.Printf("Throttled authentication for user %s from %s\n", username, c.ClientIP())
+			models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginThrottled, Username: username, IpAddress: c.ClientIP(), Detail: "password"})
+			return nil, err
+		}
+
+		user, err := TryAuthenticate(username, password, c.ClientIP())
 		if err != nil {
 			log.Printf("Failed authentication for user %s: %v\n", username, err)
+			limiter.fail(keys...)
+			if errors.Is(err, ErrAccountLocked) {
+				return nil, err
+			}
 			return nil, jwt.ErrFailedAuthentication
 		}
+		limiter.reset(keys[0])
 		return user, nil
 	}
 }
@@ -117,25 +164,88 @@ func VerifyPassword(password, hashedPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func TryAuthenticate(username, password string) (*models.User, error) {
+var ErrUserInactive = errors.New("user is inactive")
+
+// TryAuthenticate checks the password of the user. Failed logins are recorded in the audit trail and count towards
+// the lockout of the account.
+func TryAuthenticate(username, password, ip string) (*models.User, error) {
 	var user models.User
 
 	if err := models.DB.Where("name = ?", username).First(&user).Error; err != nil {
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginFailed, Username: username, IpAddress: ip, Detail: "unknown user"})
 		return nil, err
 	}
 
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return nil, fmt.Errorf("%w until %s", ErrAccountLocked, user.LockedUntil.Format(time.RFC3339))
+	}
+
 	if err := VerifyPassword(password, user.Password); err != nil {
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginFailed, UserId: &user.UserID, Username: username, IpAddress: ip, Detail: "wrong password"})
+		recordFailedLogin(&user, ip)
 		return nil, err
 	}
 
+	if user.FailedLogins != 0 || user.LockedUntil != nil {
+		models.DB.Model(&user).Updates(map[string]any{"failed_logins": 0, "locked_until": nil})
+	}
+
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+
 	return &user, nil
 }
 
+// OptionalUser returns the active user of the token sent with the request, or nil if there is no valid token. It can
+// be used on routes which work without authentication but return more to authenticated users.
+func OptionalUser(c *gin.Context) *models.User {
+	claims, err := JWTAuthMiddleware.GetClaimsFromJWT(c)
+	if err != nil || !isSessionActive(claims["sid"]) {
+		return nil
+	}
+
+	var user models.User
+	if err := models.DB.Preload("Roles").Where("user_id = ?", claims["userId"]).First(&user).Error; err != nil || !user.IsActive {
+		return nil
+	}
+	return &user
+}
+
+// CurrentUser returns the user loaded for the current request by the JWT middleware.
+func CurrentUser(c *gin.Context) *models.User {
+	user, _ := c.Get(JWTAuthMiddleware.IdentityKey)
+	if v, ok := user.(*models.User); ok {
+		return v
+	}
+	return nil
+}
+
 func IsUserAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if jwt.ExtractClaims(c)["admin"].(bool) != true {
+		if user := CurrentUser(c); user == nil || !user.IsAdmin {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 	}
 }
+
+func IsUserTrusted() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); user == nil || (!user.IsTrusted && !user.HasPermission(models.PermissionBalancesManage)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+	}
+}
+
+// RequirePermission only lets users through who are admins or have a role with the permission. It has to be used
+// after the JWT middleware.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); user == nil || !user.HasPermission(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permission " + string(permission)})
+			return
+		}
+	}
+}
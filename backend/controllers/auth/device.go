@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"metalab/metadrinks/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DeviceHeaderName = "X-Device-Token"
+	DeviceCookieName = "drinks_pos_device"
+	DeviceQueryName  = "device_token" // for EventSource clients, which cannot set headers
+
+	deviceKey = "device"
+)
+
+// readDeviceCredential returns the device credential from the header, the cookie or the query, in that order.
+func readDeviceCredential(c *gin.Context) string {
+	if credential := strings.TrimSpace(c.GetHeader(DeviceHeaderName)); credential != "" {
+		return credential
+	}
+	if credential, err := c.Cookie(DeviceCookieName); err == nil && credential != "" {
+		return credential
+	}
+	return c.Query(DeviceQueryName)
+}
+
+// loadDevice looks up the device of the credential sent with the request. It returns false if a credential was sent
+// but is not valid.
+func loadDevice(c *gin.Context) bool {
+	credential := readDeviceCredential(c)
+	if credential == "" {
+		return true
+	}
+
+	var device models.Device
+	if err := models.DB.Where("credential_hash = ?", models.HashSecret(credential)).First(&device).Error; err != nil {
+		return false
+	}
+
+	now := time.Now()
+	if device.LastSeenAt == nil || now.Sub(*device.LastSeenAt) > time.Minute {
+		models.DB.Model(&device).Update("last_seen_at", now)
+	}
+	device.LastSeenAt = &now
+	c.Set(deviceKey, &device)
+	return true
+}
+
+// RequireDevice only lets requests from registered kiosks through.
+func RequireDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !loadDevice(c) || CurrentDevice(c) == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "valid device credential required"})
+			return
+		}
+	}
+}
+
+// OptionalDevice loads the kiosk the request was made from, if any. Invalid credentials are rejected.
+func OptionalDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !loadDevice(c) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid device credential"})
+			return
+		}
+	}
+}
+
+// CurrentDevice returns the kiosk the request was made from, or nil.
+func CurrentDevice(c *gin.Context) *models.Device {
+	device, _ := c.Get(deviceKey)
+	if v, ok := device.(*models.Device); ok {
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const OidcStateCookieName = "drinks_pos_oidc"
+
+var (
+	ErrOidcDisabled      = errors.New("single sign-on is not configured")
+	ErrOidcInvalidState  = errors.New("invalid or expired login state")
+	ErrOidcNotLinked     = errors.New("no user is linked to this account")
+	ErrOidcAlreadyLinked = errors.New("this account is already linked to another user")
+	ErrOidcNameTaken     = errors.New("username is already taken, ask an admin to link your account")
+)
+
+// oidcLogin is a login which was started at the identity provider and has not come back yet.
+type oidcLogin struct {
+	nonce     string
+	verifier  string
+	linkUser  *uuid.UUID // set if the login links the account to an existing user instead of logging in
+	expiresAt time.Time
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+	oidcLogins   = make(map[string]*oidcLogin)
+)
+
+// IsOidcEnabled returns whether single sign-on is configured with OIDC_ISSUER.
+func IsOidcEnabled() bool {
+	return os.Getenv("OIDC_ISSUER") != ""
+}
+
+// getOidcProvider discovers the identity provider on first use, so the backend still starts while it is unreachable.
+func getOidcProvider(ctx context.Context) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProvider == nil {
+		provider, err := oidc.NewProvider(ctx, os.Getenv("OIDC_ISSUER"))
+		if err != nil {
+			return nil, err
+		}
+		oidcProvider = provider
+	}
+	return oidcProvider, nil
+}
+
+func getOidcConfig(provider *oidc.Provider) *oauth2.Config {
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+}
+
+// storeOidcLogin remembers the login under its state and forgets expired logins.
+func storeOidcLogin(state string, login *oidcLogin) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	now := time.Now()
+	for k, v := range oidcLogins {
+		if v.expiresAt.Before(now) {
+			delete(oidcLogins, k)
+		}
+	}
+	oidcLogins[state] = login
+}
+
+// takeOidcLogin returns the login of the state once.
+func takeOidcLogin(state string) *oidcLogin {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	login, ok := oidcLogins[state]
+	if !ok {
+		return nil
+	}
+	delete(oidcLogins, state)
+	if login.expiresAt.Before(time.Now()) {
+		return nil
+	}
+	return login
+}
+
+// OidcLoginHandler redirects to the identity provider using the authorization code flow with PKCE. With ?link=true, a
+// logged-in user links the account at the identity provider to their user instead.
+func OidcLoginHandler(c *gin.Context) {
+	if !IsOidcEnabled() {
+		unauthorized()(c, http.StatusNotFound, ErrOidcDisabled.Error())
+		return
+	}
+
+	provider, err := getOidcProvider(c.Request.Context())
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v\n", err)
+		unauthorized()(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	login := &oidcLogin{verifier: oauth2.GenerateVerifier(), expiresAt: time.Now().Add(10 * time.Minute)}
+	if c.Query("link") == "true" {
+		user := OptionalUser(c)
+		if user == nil {
+			unauthorized()(c, http.StatusUnauthorized, jwt.ErrForbidden.Error())
+			return
+		}
+		login.linkUser = &user.UserID
+	}
+
+	state, err := GenerateSecret()
+	if err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if login.nonce, err = GenerateSecret(); err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	storeOidcLogin(state, login)
+
+	// the identity provider redirects back cross-site, so the cookie cannot be strict
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OidcStateCookieName, state, int((10 * time.Minute).Seconds()), "/auth/oidc", JWTAuthMiddleware.CookieDomain, JWTAuthMiddleware.SecureCookie, true)
+
+	url := getOidcConfig(provider).AuthCodeURL(state, oidc.Nonce(login.nonce), oauth2.S256ChallengeOption(login.verifier))
+	c.Redirect(http.StatusFound, url)
+}
+
+// OidcCallbackHandler finishes the login at the identity provider. Users are found by their subject and created on
+// their first login if OIDC_AUTO_PROVISION is set.
+func OidcCallbackHandler(c *gin.Context) {
+	if !IsOidcEnabled() {
+		unauthorized()(c, http.StatusNotFound, ErrOidcDisabled.Error())
+		return
+	}
+
+	state, _ := c.Cookie(OidcStateCookieName)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OidcStateCookieName, "", -1, "/auth/oidc", JWTAuthMiddleware.CookieDomain, JWTAuthMiddleware.SecureCookie, true)
+	if state == "" || state != c.Query("state") {
+		unauthorized()(c, http.StatusBadRequest, ErrOidcInvalidState.Error())
+		return
+	}
+	login := takeOidcLogin(state)
+	if login == nil {
+		unauthorized()(c, http.StatusBadRequest, ErrOidcInvalidState.Error())
+		return
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		unauthorized()(c, http.StatusUnauthorized, fmt.Sprintf("identity provider: %s %s", errorCode, c.Query("error_description")))
+		return
+	}
+
+	provider, err := getOidcProvider(c.Request.Context())
+	if err != nil {
+		unauthorized()(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	config := getOidcConfig(provider)
+
+	oauth2Token, err := config.Exchange(c.Request.Context(), c.Query("code"), oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v\n", err)
+		unauthorized()(c, http.StatusUnauthorized, jwt.ErrFailedAuthentication.Error())
+		return
+	}
+	rawIdToken, ok := oauth2Token.Extra("id_token").(string)
+	if !ok {
+		unauthorized()(c, http.StatusUnauthorized, "identity provider did not return an id token")
+		return
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.ClientID}).Verify(c.Request.Context(), rawIdToken)
+	if err != nil || idToken.Nonce != login.nonce {
+		log.Printf("OIDC id token rejected: %v\n", err)
+		unauthorized()(c, http.StatusUnauthorized, jwt.ErrFailedAuthentication.Error())
+		return
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		unauthorized()(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if login.linkUser != nil {
+		linkOidcUser(c, *login.linkUser, idToken.Subject)
+		return
+	}
+
+	user, err := findOidcUser(idToken.Subject, claims)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, ErrOidcNameTaken) {
+			status = http.StatusConflict
+		}
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginFailed, IpAddress: c.ClientIP(), Detail: fmt.Sprintf("oidc subject %s: %s", idToken.Subject, err.Error())})
+		unauthorized()(c, status, err.Error())
+		return
+	}
+
+	applyOidcGroups(user, claims)
+	finishOidcLogin(c, user)
+}
+
+// findOidcUser returns the user linked to the subject, or creates one if OIDC_AUTO_PROVISION is set.
+func findOidcUser(subject string, claims map[string]any) (*models.User, error) {
+	var user models.User
+	err := models.DB.Unscoped().Where("oidc_subject = ?", subject).First(&user).Error
+	if err == nil {
+		if !user.IsActive || user.DeletedAt.Valid {
+			return nil, ErrUserInactive
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !libs.GetEnvBool("OIDC_AUTO_PROVISION", false) {
+		return nil, ErrOidcNotLinked
+	}
+
+	usernameClaim := os.Getenv("OIDC_USERNAME_CLAIM")
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	name, _ := claims[usernameClaim].(string)
+	if name == "" {
+		name = subject
+	}
+
+	var count int64
+	models.DB.Unscoped().Model(&models.User{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return nil, ErrOidcNameTaken
+	}
+
+	user = models.User{Name: name, OidcSubject: &subject, IsActive: true}
+	if err := models.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	fmt.Printf("[INFO] OIDC: Created user %s for subject %s\n", user.Name, subject)
+	return &user, nil
+}
+
+// oidcGroups returns the groups of the user from the claim named in OIDC_GROUPS_CLAIM (default groups). Identity
+// providers send either a list or a single string.
+func oidcGroups(claims map[string]any) []string {
+	groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	var groups []string
+	switch v := claims[groupsClaim].(type) {
+	case string:
+		groups = append(groups, v)
+	case []any:
+		for _, group := range v {
+			if s, ok := group.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return groups
+}
+
+// applyOidcGroups sets the admin and trusted flags from the groups at the identity provider on every login. Flags
+// whose group (OIDC_ADMIN_GROUP, OIDC_TRUSTED_GROUP) is not set are managed in the backend only.
+func applyOidcGroups(user *models.User, claims map[string]any) {
+	groups := oidcGroups(claims)
+	updates := map[string]any{}
+
+	if adminGroup := os.Getenv("OIDC_ADMIN_GROUP"); adminGroup != "" {
+		user.IsAdmin = slices.Contains(groups, adminGroup)
+		updates["is_admin"] = user.IsAdmin
+	}
+	if trustedGroup := os.Getenv("OIDC_TRUSTED_GROUP"); trustedGroup != "" {
+		user.IsTrusted = slices.Contains(groups, trustedGroup)
+		updates["is_trusted"] = user.IsTrusted
+	}
+
+	if len(updates) > 0 {
+		models.DB.Model(user).Updates(updates)
+	}
+}
+
+// linkOidcUser links the subject to the user who started the login.
+func linkOidcUser(c *gin.Context, userId uuid.UUID, subject string) {
+	var count int64
+	models.DB.Unscoped().Model(&models.User{}).Where("oidc_subject = ? AND user_id <> ?", subject, userId).Count(&count)
+	if count > 0 {
+		unauthorized()(c, http.StatusConflict, ErrOidcAlreadyLinked.Error())
+		return
+	}
+
+	var user models.User
+	if err := models.DB.Where("user_id = ?", userId).First(&user).Error; err != nil || !user.IsActive {
+		unauthorized()(c, http.StatusUnauthorized, ErrUserInactive.Error())
+		return
+	}
+	if err := models.DB.Model(&user).Update("oidc_subject", subject).Error; err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if redirectUrl := os.Getenv("OIDC_POST_LOGIN_URL"); redirectUrl != "" {
+		c.Redirect(http.StatusFound, redirectUrl)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
+
+// finishOidcLogin creates a session for the user. Browsers are sent to OIDC_POST_LOGIN_URL with the session in the
+// cookies, without it the tokens are returned like for the other logins.
+func finishOidcLogin(c *gin.Context, user *models.User) {
+	redirectUrl := os.Getenv("OIDC_POST_LOGIN_URL")
+	if redirectUrl == "" {
+		issueSession(c, user)
+		return
+	}
+
+	session, refreshToken, err := createSession(c, user)
+	if err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	token, _, err := JWTAuthMiddleware.TokenGenerator(&sessionUser{User: user, SessionId: session.SessionId})
+	if err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	JWTAuthMiddleware.SetCookie(c, token)
+	setRefreshCookie(c, refreshToken, int(time.Until(session.ExpiresAt).Seconds()))
+	c.Redirect(http.StatusFound, redirectUrl)
+}
+
+// UnlinkOidc removes the link between the logged-in user and their account at the identity provider.
+func UnlinkOidc(c *gin.Context) {
+	user := CurrentUser(c)
+	if user.OidcSubject != nil && user.Password == "" {
+		unauthorized()(c, http.StatusBadRequest, "set a password before unlinking, otherwise you cannot log in anymore")
+		return
+	}
+
+	if err := models.DB.Model(user).Update("oidc_subject", nil).Error; err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	user.OidcSubject = nil
+
+	c.JSON(http.StatusOK, gin.H{"data": user.Private()})
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOidcGroups(t *testing.T) {
+	tests := []struct {
+		name   string
+		claim  string
+		claims map[string]any
+		want   []string
+	}{
+		{"list", "", map[string]any{"groups": []any{"members", "admins"}}, []string{"members", "admins"}},
+		{"single string", "", map[string]any{"groups": "admins"}, []string{"admins"}},
+		{"missing", "", map[string]any{"preferred_username": "alice"}, nil},
+		{"non-string entries", "", map[string]any{"groups": []any{"members", 42, nil, map[string]any{}}}, []string{"members"}},
+		{"wrong type", "", map[string]any{"groups": 42.0}, nil},
+		{"custom claim", "roles", map[string]any{"roles": []any{"admins"}, "groups": []any{"members"}}, []string{"admins"}},
+		{"custom claim missing", "roles", map[string]any{"groups": []any{"members"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OIDC_GROUPS_CLAIM", tt.claim)
+			if got := oidcGroups(tt.claims); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTakeOidcLogin(t *testing.T) {
+	storeOidcLogin("valid", &oidcLogin{expiresAt: time.Now().Add(time.Minute)})
+	storeOidcLogin("expired", &oidcLogin{expiresAt: time.Now().Add(-time.Minute)})
+
+	if takeOidcLogin("valid") == nil {
+		t.Error("expected the login to be found")
+	}
+	if takeOidcLogin("valid") != nil {
+		t.Error("expected the state to be usable only once")
+	}
+	if takeOidcLogin("expired") != nil {
+		t.Error("expected expired logins to be rejected")
+	}
+	if takeOidcLogin("unknown") != nil {
+		t.Error("expected unknown states to be rejected")
+	}
+}
+
+// testOidcProvider is an identity provider serving discovery, its signing key and a token endpoint which returns
+// idToken as the id token.
+type testOidcProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu      sync.Mutex
+	idToken string
+}
+
+const testOidcClientId = "metadrinks"
+
+func newTestOidcProvider(t *testing.T) *testOidcProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testOidcProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, map[string]any{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, map[string]any{"keys": []map[string]any{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		writeJson(w, map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 3600, "id_token": p.idToken})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	t.Setenv("OIDC_ISSUER", p.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", testOidcClientId)
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_REDIRECT_URL", "http://localhost/auth/oidc/callback")
+	t.Setenv("OIDC_POST_LOGIN_URL", "")
+
+	oidcMu.Lock()
+	oidcProvider = nil
+	oidcMu.Unlock()
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcProvider = nil
+		oidcMu.Unlock()
+	})
+	return p
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// sign returns an RS256 id token with the default claims of the provider overridden by claims.
+func (p *testOidcProvider) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload := map[string]any{
+		"iss": p.server.URL,
+		"sub": "subject",
+		"aud": testOidcClientId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test"})
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *testOidcProvider) issue(idToken string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idToken = idToken
+}
+
+func TestOidcProviderVerifiesIdToken(t *testing.T) {
+	p := newTestOidcProvider(t)
+	t.Setenv("OIDC_GROUPS_CLAIM", "")
+
+	provider, err := getOidcProvider(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getOidcConfig(provider).Endpoint.TokenURL; got != p.server.URL+"/token" {
+		t.Errorf("expected the token endpoint from discovery, got %s", got)
+	}
+	verifier := provider.Verifier(&oidc.Config{ClientID: testOidcClientId})
+
+	idToken, err := verifier.Verify(t.Context(), p.sign(t, map[string]any{"groups": []any{"admins"}}))
+	if err != nil {
+		t.Fatalf("expected the id token to be accepted, got %v", err)
+	}
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if groups := oidcGroups(claims); !slices.Equal(groups, []string{"admins"}) {
+		t.Errorf("expected the groups of the id token, got %v", groups)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := &testOidcProvider{server: p.server, key: other}
+
+	rejected := map[string]string{
+		"wrong audience": p.sign(t, map[string]any{"aud": "other-client"}),
+		"wrong issuer":   p.sign(t, map[string]any{"iss": "https://example.com"}),
+		"expired":        p.sign(t, map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}),
+		"wrong key":      forged.sign(t, nil),
+	}
+	for name, token := range rejected {
+		if _, err := verifier.Verify(t.Context(), token); err == nil {
+			t.Errorf("%s: expected the id token to be rejected", name)
+		}
+	}
+}
+
+func TestOidcCallbackRejectsInvalidLogins(t *testing.T) {
+	p := newTestOidcProvider(t)
+	gin.SetMode(gin.TestMode)
+	if JWTAuthMiddleware == nil {
+		JWTAuthMiddleware = &jwt.GinJWTMiddleware{}
+		t.Cleanup(func() { JWTAuthMiddleware = nil })
+	}
+
+	router := gin.New()
+	router.GET("/auth/oidc/login", OidcLoginHandler)
+	router.GET("/auth/oidc/callback", OidcCallbackHandler)
+
+	// startLogin returns the state cookie and the nonce sent to the identity provider
+	startLogin := func() (*http.Cookie, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected a redirect to the identity provider, got %d: %s", w.Code, w.Body.String())
+		}
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if location.Scheme+"://"+location.Host+location.Path != p.server.URL+"/authorize" {
+			t.Fatalf("expected a redirect to the authorization endpoint, got %s", location)
+		}
+		query := location.Query()
+		if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+			t.Errorf("expected a PKCE challenge, got %s", location.RawQuery)
+		}
+
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == OidcStateCookieName {
+				if cookie.Value != query.Get("state") {
+					t.Fatal("expected the state cookie to match the state sent to the identity provider")
+				}
+				return cookie, query.Get("nonce")
+			}
+		}
+		t.Fatal("expected a state cookie")
+		return nil, ""
+	}
+
+	callback := func(cookie *http.Cookie, state string) int {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=code&state="+url.QueryEscape(state), nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("missing state cookie", func(t *testing.T) {
+		cookie, _ := startLogin()
+		if code := callback(nil, cookie.Value); code != http.StatusBadRequest {
+			t.Errorf("expected %d, got %d", http.StatusBadRequest, code)
+		}
+	})
+
+	t.Run("state does not match cookie", func(t *testing.T) {
+		cookie, _ := startLogin()
+		if code := callback(cookie, "other"); code != http.StatusBadRequest {
+			t.Errorf("expected %d, got %d", http.StatusBadRequest, code)
+		}
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		cookie, _ := startLogin()
+		p.issue(p.sign(t, map[string]any{"nonce": "other"}))
+		if code := callback(cookie, cookie.Value); code != http.StatusUnauthorized {
+			t.Errorf("expected %d, got %d", http.StatusUnauthorized, code)
+		}
+		if code := callback(cookie, cookie.Value); code != http.StatusBadRequest {
+			t.Errorf("expected the state to be used up, got %d", code)
+		}
+	})
+
+	t.Run("token signed by another key", func(t *testing.T) {
+		cookie, nonce := startLogin()
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		forged := &testOidcProvider{server: p.server, key: other}
+		p.issue(forged.sign(t, map[string]any{"nonce": nonce}))
+		if code := callback(cookie, cookie.Value); code != http.StatusUnauthorized {
+			t.Errorf("expected %d, got %d", http.StatusUnauthorized, code)
+		}
+	})
+
+	t.Run("malformed id token", func(t *testing.T) {
+		cookie, _ := startLogin()
+		p.issue("")
+		if code := callback(cookie, cookie.Value); code != http.StatusUnauthorized {
+			t.Errorf("expected %d, got %d", http.StatusUnauthorized, code)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPin = errors.New("pin must consist of 4 to 6 digits")
+	ErrNoPin      = errors.New("user has no pin")
+	ErrWrongPin   = errors.New("wrong pin")
+	ErrPinLocked  = errors.New("pin login is locked")
+)
+
+var pinPattern = regexp.MustCompile(`^[0-9]{4,6}$`)
+
+// HashPin checks the format of the pin and returns its bcrypt hash.
+func HashPin(pin string) (string, error) {
+	if !pinPattern.MatchString(pin) {
+		return "", ErrInvalidPin
+	}
+
+	hashedPin, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPin), nil
+}
+
+// VerifyPin checks the pin of the user. After PIN_MAX_ATTEMPTS (default 5) wrong pins in a row, pins of the user are
+// refused for PIN_LOCKOUT (default 5m). The wrong pins keep counting after the lockout expired, and every further wrong
+// pin locks them again for twice as long, up to PIN_LOCKOUT_MAX (default 24h). Only a correct pin or an admin resets
+// the count.
+func VerifyPin(user *models.User, pin string) error {
+	if user.PinHash == "" {
+		return ErrNoPin
+	}
+	if user.PinLockUntil != nil && user.PinLockUntil.After(time.Now()) {
+		return fmt.Errorf("%w until %s", ErrPinLocked, user.PinLockUntil.Format(time.RFC3339))
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(pin)); err != nil {
+		user.PinAttempts++
+		updates := map[string]any{"pin_attempts": gorm.Expr("pin_attempts + 1")}
+		if maxAttempts := libs.GetEnvInt("PIN_MAX_ATTEMPTS", 5); user.PinAttempts >= maxAttempts {
+			lockedUntil := time.Now().Add(pinLockout(user.PinAttempts - maxAttempts))
+			user.PinLockUntil = &lockedUntil
+			updates["pin_lock_until"] = lockedUntil
+			log.Printf("PIN login of user %s locked until %s after %d wrong pins\n", user.UserID, lockedUntil.Format(time.RFC3339), user.PinAttempts)
+		}
+		models.DB.Model(user).Updates(updates)
+		return ErrWrongPin
+	}
+
+	if user.PinAttempts != 0 || user.PinLockUntil != nil {
+		user.PinAttempts = 0
+		user.PinLockUntil = nil
+		models.DB.Model(user).Updates(map[string]any{"pin_attempts": 0, "pin_lock_until": nil})
+	}
+	return nil
+}
+
+// pinLockout returns how long the pin is locked after the given number of previous lockouts.
+func pinLockout(previousLockouts int) time.Duration {
+	lockout := libs.GetEnvDuration("PIN_LOCKOUT", 5*time.Minute)
+	maxLockout := libs.GetEnvDuration("PIN_LOCKOUT_MAX", 24*time.Hour)
+
+	for range previousLockouts {
+		lockout *= 2
+		if lockout >= maxLockout || lockout <= 0 {
+			return maxLockout
+		}
+	}
+	return min(lockout, maxLockout)
+}
+
+type PinLoginForm struct {
+	UserId uuid.UUID `form:"user_id" json:"user_id" binding:"required"`
+	Pin    string    `form:"pin" json:"pin" binding:"required"`
+}
+
+// PinLoginHandler logs in the user with their pin instead of their password.
+func PinLoginHandler(c *gin.Context) {
+	var form PinLoginForm
+	if err := c.ShouldBind(&form); err != nil {
+		unauthorized()(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	keys := []string{usernameKey(form.UserId.String()), ipKey(c.ClientIP())}
+	if err := limiter.check(keys...); err != nil {
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginThrottled, UserId: &form.UserId, IpAddress: c.ClientIP(), Detail: "pin"})
+		unauthorized()(c, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	var user models.User
+	if err := models.DB.Where("user_id = ?", form.UserId).First(&user).Error; err != nil || !user.IsActive {
+		limiter.fail(keys...)
+		unauthorized()(c, http.StatusUnauthorized, jwt.ErrFailedAuthentication.Error())
+		return
+	}
+
+	if err := VerifyPin(&user, form.Pin); err != nil {
+		log.Printf("Failed PIN authentication for user %s: %v\n", user.UserID, err)
+		limiter.fail(keys...)
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginFailed, UserId: &user.UserID, Username: user.Name, IpAddress: c.ClientIP(), Detail: "pin: " + err.Error()})
+		if errors.Is(err, ErrPinLocked) {
+			unauthorized()(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		unauthorized()(c, http.StatusUnauthorized, jwt.ErrFailedAuthentication.Error())
+		return
+	}
+	limiter.reset(keys[0])
+
+	issueSession(c, &user)
+}
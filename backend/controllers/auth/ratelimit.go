@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+	ErrAccountLocked   = errors.New("account is locked")
+)
+
+type loginAttempts struct {
+	failures     int
+	blockedUntil time.Time
+	lastFailure  time.Time
+}
+
+// loginLimiter throttles logins per username and per ip address in memory. After LOGIN_FREE_ATTEMPTS (default 3)
+// failures, every further failure blocks the key for LOGIN_BACKOFF_BASE (default 1s), doubled with every failure up to
+// LOGIN_BACKOFF_MAX (default 5m).
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts
+}
+
+var limiter = &loginLimiter{attempts: make(map[string]*loginAttempts)}
+
+func usernameKey(username string) string {
+	return "user:" + username
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// check returns ErrTooManyAttempts if one of the keys is blocked.
+func (l *loginLimiter) check(keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		if attempts, ok := l.attempts[key]; ok && attempts.blockedUntil.After(now) {
+			return fmt.Errorf("%w, try again in %s", ErrTooManyAttempts, attempts.blockedUntil.Sub(now).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// fail records a failed login for all keys.
+func (l *loginLimiter) fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+	freeAttempts := libs.GetEnvInt("LOGIN_FREE_ATTEMPTS", 3)
+	base := libs.GetEnvDuration("LOGIN_BACKOFF_BASE", time.Second)
+	maxBackoff := libs.GetEnvDuration("LOGIN_BACKOFF_MAX", 5*time.Minute)
+
+	for _, key := range keys {
+		attempts, ok := l.attempts[key]
+		if !ok {
+			attempts = &loginAttempts{}
+			l.attempts[key] = attempts
+		}
+		attempts.failures++
+		attempts.lastFailure = now
+
+		if attempts.failures > freeAttempts {
+			backoff := time.Duration(float64(base) * math.Pow(2, float64(attempts.failures-freeAttempts-1)))
+			if backoff > maxBackoff || backoff <= 0 {
+				backoff = maxBackoff
+			}
+			attempts.blockedUntil = now.Add(backoff)
+		}
+	}
+}
+
+// reset forgets the failures of the keys, e.g. after a successful login.
+func (l *loginLimiter) reset(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		delete(l.attempts, key)
+	}
+}
+
+// cleanup removes keys without failures for an hour. It has to be called with the lock held.
+func (l *loginLimiter) cleanup(now time.Time) {
+	for key, attempts := range l.attempts {
+		if now.Sub(attempts.lastFailure) > time.Hour && attempts.blockedUntil.Before(now) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// recordFailedLogin counts a failed password login against the account of the user. After LOGIN_MAX_FAILURES
+// (default 10) failures in a row, the account is locked for LOGIN_LOCKOUT (default 15m) or until an admin unlocks it.
+func recordFailedLogin(user *models.User, ip string) {
+	var counted models.User
+
+	// increment in the database, concurrent failed logins must not overwrite each other's count
+	result := models.DB.Model(&counted).Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_logins"}}}).Where("user_id = ?", user.UserID).Update("failed_logins", gorm.Expr("failed_logins + 1"))
+	if result.Error != nil {
+		fmt.Printf("error while counting failed login: %s\n", result.Error.Error())
+		return
+	}
+	user.FailedLogins = counted.FailedLogins
+
+	if user.FailedLogins >= libs.GetEnvInt("LOGIN_MAX_FAILURES", 10) {
+		lockedUntil := time.Now().Add(libs.GetEnvDuration("LOGIN_LOCKOUT", 15*time.Minute))
+		if err := models.DB.Model(user).Update("locked_until", lockedUntil).Error; err != nil {
+			fmt.Printf("error while locking account: %s\n", err.Error())
+			return
+		}
+		user.LockedUntil = &lockedUntil
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventAccountLocked, UserId: &user.UserID, Username: user.Name, IpAddress: ip, Detail: fmt.Sprintf("locked until %s after %d failed logins", lockedUntil.Format(time.RFC3339), user.FailedLogins)})
+	}
+}
+
+// UnlockUser resets the failed logins and pin attempts of the user and lifts all lockouts and throttling of their
+// username.
+func UnlockUser(user *models.User, adminId uuid.UUID, ip string) error {
+	err := models.DB.Model(user).Updates(map[string]any{"failed_logins": 0, "locked_until": nil, "pin_attempts": 0, "pin_lock_until": nil}).Error
+	if err != nil {
+		return err
+	}
+
+	limiter.reset(usernameKey(user.Name), usernameKey(user.UserID.String()))
+	models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventAccountUnlocked, UserId: &user.UserID, Username: user.Name, IpAddress: ip, CreatedBy: &adminId})
+	return nil
+}
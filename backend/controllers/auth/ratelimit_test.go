@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"metalab/metadrinks/models"
+
+	"github.com/google/uuid"
+)
+
+func newTestLimiter(t *testing.T) *loginLimiter {
+	t.Helper()
+	t.Setenv("LOGIN_FREE_ATTEMPTS", "3")
+	t.Setenv("LOGIN_BACKOFF_BASE", "1m")
+	t.Setenv("LOGIN_BACKOFF_MAX", "5m")
+	return &loginLimiter{attempts: make(map[string]*loginAttempts)}
+}
+
+func backoff(l *loginLimiter, key string) time.Duration {
+	attempts := l.attempts[key]
+	return attempts.blockedUntil.Sub(attempts.lastFailure)
+}
+
+func TestLoginLimiterFreeAttempts(t *testing.T) {
+	l := newTestLimiter(t)
+	key := usernameKey("alice")
+
+	for i := range 3 {
+		if err := l.check(key); err != nil {
+			t.Fatalf("attempt %d: expected no block, got %v", i+1, err)
+		}
+		l.fail(key)
+	}
+	if err := l.check(key); err != nil {
+		t.Errorf("expected no block after the free attempts, got %v", err)
+	}
+
+	l.fail(key)
+	if err := l.check(key); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("expected ErrTooManyAttempts after exceeding the free attempts, got %v", err)
+	}
+}
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	l := newTestLimiter(t)
+	key := ipKey("192.0.2.1")
+
+	for range 3 {
+		l.fail(key)
+	}
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, want := range expected {
+		l.fail(key)
+		if got := backoff(l, key); got != want {
+			t.Errorf("failure %d: expected backoff %s, got %s", i+4, want, got)
+		}
+	}
+}
+
+func TestLoginLimiterBackoffOverflow(t *testing.T) {
+	l := newTestLimiter(t)
+	key := ipKey("192.0.2.1")
+
+	for range 200 {
+		l.fail(key)
+	}
+	if got := backoff(l, key); got != 5*time.Minute {
+		t.Errorf("expected the backoff to stay at the maximum, got %s", got)
+	}
+}
+
+func TestLoginLimiterReset(t *testing.T) {
+	l := newTestLimiter(t)
+	key := usernameKey("alice")
+
+	for range 5 {
+		l.fail(key)
+	}
+	if err := l.check(key); err == nil {
+		t.Fatal("expected the key to be blocked")
+	}
+
+	l.reset(key)
+	if err := l.check(key); err != nil {
+		t.Errorf("expected no block after reset, got %v", err)
+	}
+	l.fail(key)
+	if err := l.check(key); err != nil {
+		t.Errorf("expected the free attempts to start over after reset, got %v", err)
+	}
+}
+
+func TestLoginLimiterIndependentKeys(t *testing.T) {
+	l := newTestLimiter(t)
+	blocked, other := usernameKey("alice"), usernameKey("bob")
+
+	for range 5 {
+		l.fail(blocked)
+	}
+	if err := l.check(other); err != nil {
+		t.Errorf("expected other keys to be unaffected, got %v", err)
+	}
+	if err := l.check(other, blocked); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("expected check to fail if any key is blocked, got %v", err)
+	}
+}
+
+func TestLoginLimiterCleanup(t *testing.T) {
+	l := newTestLimiter(t)
+	stale, fresh := ipKey("192.0.2.1"), ipKey("192.0.2.2")
+
+	l.fail(stale, fresh)
+	l.attempts[stale].lastFailure = time.Now().Add(-2 * time.Hour)
+	l.cleanup(time.Now())
+
+	if _, ok := l.attempts[stale]; ok {
+		t.Error("expected the stale key to be removed")
+	}
+	if _, ok := l.attempts[fresh]; !ok {
+		t.Error("expected the fresh key to be kept")
+	}
+}
+
+var connectTestDatabase sync.Once
+
+// setupTestDatabase connects to the database configured with the DB_* variables. The tests create and delete their
+// own rows, but should still only be run against a scratch database.
+func setupTestDatabase(t *testing.T) {
+	t.Helper()
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST is not set, skipping database test")
+	}
+	connectTestDatabase.Do(models.ConnectDatabase)
+}
+
+func TestConcurrentFailedLogins(t *testing.T) {
+	setupTestDatabase(t)
+	t.Setenv("LOGIN_MAX_FAILURES", "10")
+
+	const failures = 10
+
+	user := models.User{Name: "test-" + uuid.NewString(), IsActive: true, UsedAt: time.Now()}
+	if err := models.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		models.DB.Where("user_id = ?", user.UserID).Delete(&models.AuditEvent{})
+		models.DB.Unscoped().Delete(&user)
+	})
+
+	var wg sync.WaitGroup
+	for range failures {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stale := user // every request loaded the user before the others counted
+			recordFailedLogin(&stale, "192.0.2.1")
+		}()
+	}
+	wg.Wait()
+
+	if err := models.DB.Where("user_id = ?", user.UserID).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.FailedLogins != failures {
+		t.Errorf("expected %d failed logins, got %d", failures, user.FailedLogins)
+	}
+	if user.LockedUntil == nil || !user.LockedUntil.After(time.Now()) {
+		t.Error("expected the account to be locked")
+	}
+}
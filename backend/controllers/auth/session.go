@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RefreshCookieName = "drinks_pos_refresh"
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// sessionUser is passed to the token generator, so the id of the session ends up in the claims of the access token.
+type sessionUser struct {
+	*models.User
+	SessionId uuid.UUID
+}
+
+// GetAccessTokenTTL returns how long access tokens are valid, set in ACCESS_TOKEN_TTL. Defaults to 15 minutes.
+func GetAccessTokenTTL() time.Duration {
+	return libs.GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// GetRefreshTokenTTL returns how long sessions stay valid without being refreshed, set in REFRESH_TOKEN_TTL. Defaults
+// to 30 days.
+func GetRefreshTokenTTL() time.Duration {
+	return libs.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// GenerateSecret returns 32 random bytes in hex, used for refresh tokens and device credentials.
+func GenerateSecret() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// issueSession creates a new session for the user and responds with an access token and a refresh token. All login
+// methods end here.
+func issueSession(c *gin.Context, user *models.User) {
+	session, refreshToken, err := createSession(c, user)
+	if err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithTokens(c, user, session, refreshToken)
+}
+
+// createSession stores a new session for the user and returns it with its refresh token.
+func createSession(c *gin.Context, user *models.User) (*models.Session, string, error) {
+	refreshToken, err := GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserId:           user.UserID,
+		RefreshTokenHash: models.HashSecret(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		IpAddress:        c.ClientIP(),
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(GetRefreshTokenTTL()),
+	}
+	if err := models.DB.Create(&session).Error; err != nil {
+		return nil, "", err
+	}
+	models.DB.Where("user_id = ? AND (expires_at < ? OR revoked_at IS NOT NULL)", user.UserID, now).Delete(&models.Session{})
+
+	return &session, refreshToken, nil
+}
+
+func respondWithTokens(c *gin.Context, user *models.User, session *models.Session, refreshToken string) {
+	token, expire, err := JWTAuthMiddleware.TokenGenerator(&sessionUser{User: user, SessionId: session.SessionId})
+	if err != nil {
+		unauthorized()(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	JWTAuthMiddleware.SetCookie(c, token)
+	setRefreshCookie(c, refreshToken, int(time.Until(session.ExpiresAt).Seconds()))
+	c.JSON(http.StatusOK, gin.H{
+		"code":          http.StatusOK,
+		"token":         token,
+		"expire":        expire.Format(time.RFC3339),
+		"refresh_token": refreshToken,
+		"session_id":    session.SessionId,
+	})
+}
+
+// setRefreshCookie stores the refresh token in a cookie which is only sent to the auth routes.
+func setRefreshCookie(c *gin.Context, refreshToken string, maxAge int) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(RefreshCookieName, refreshToken, maxAge, "/auth", JWTAuthMiddleware.CookieDomain, JWTAuthMiddleware.SecureCookie, true)
+}
+
+type RefreshForm struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+}
+
+// readRefreshToken returns the refresh token from the refresh cookie, or from the request body for clients without
+// cookies.
+func readRefreshToken(c *gin.Context) string {
+	if refreshToken, err := c.Cookie(RefreshCookieName); err == nil && refreshToken != "" {
+		return refreshToken
+	}
+
+	var form RefreshForm
+	_ = c.ShouldBind(&form)
+	return form.RefreshToken
+}
+
+// LoginHandler logs in with username and password.
+func LoginHandler(c *gin.Context) {
+	data, err := authenticator()(c)
+	if err != nil {
+		if errors.Is(err, ErrTooManyAttempts) || errors.Is(err, ErrAccountLocked) {
+			unauthorized()(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		unauthorized()(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	issueSession(c, data.(*models.User))
+}
+
+// RefreshHandler exchanges the refresh token for a new access token and a new refresh token. The old refresh token
+// becomes invalid, and the new access token contains the current flags of the user. If a refresh token is used a
+// second time, it was probably stolen, so its session is revoked.
+func RefreshHandler(c *gin.Context) {
+	refreshToken := readRefreshToken(c)
+	if refreshToken == "" {
+		unauthorized()(c, http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
+		return
+	}
+	refreshTokenHash := models.HashSecret(refreshToken)
+
+	var session models.Session
+	if err := models.DB.Where("refresh_token_hash = ?", refreshTokenHash).First(&session).Error; err != nil {
+		if models.DB.Where("previous_hash = ?", refreshTokenHash).First(&session).Error == nil {
+			revokeReusedSession(&session, c.ClientIP())
+		}
+		unauthorized()(c, http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
+		return
+	}
+	if !session.IsActive() {
+		unauthorized()(c, http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
+		return
+	}
+
+	var user models.User
+	if err := models.DB.Where("user_id = ?", session.UserId).First(&user).Error; err != nil || !user.IsActive {
+		unauthorized()(c, http.StatusUnauthorized, ErrUserInactive.Error())
+		return
+	}
+
+	newRefreshToken, err := GenerateSecret()
+	if err != nil {
+		unauthorized()(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	now := time.Now()
+	session.RefreshTokenHash = models.HashSecret(newRefreshToken)
+	session.PreviousHash = refreshTokenHash
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(GetRefreshTokenTTL())
+	session.IpAddress = c.ClientIP()
+	// only one request can rotate the token, a concurrent request with the same token updates nothing
+	result := models.DB.Model(&session).Where("refresh_token_hash = ? AND revoked_at IS NULL", refreshTokenHash).Select("refresh_token_hash", "previous_hash", "last_used_at", "expires_at", "ip_address").Updates(&session)
+	if result.Error != nil {
+		unauthorized()(c, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+	if result.RowsAffected != 1 {
+		revokeReusedSession(&session, c.ClientIP())
+		unauthorized()(c, http.StatusUnauthorized, ErrInvalidRefreshToken.Error())
+		return
+	}
+
+	respondWithTokens(c, &user, &session, newRefreshToken)
+}
+
+// revokeReusedSession revokes a session whose refresh token was used more than once.
+func revokeReusedSession(session *models.Session, ip string) {
+	models.DB.Model(&models.Session{}).Where("session_id = ? AND revoked_at IS NULL", session.SessionId).Update("revoked_at", time.Now())
+	log.Printf("Revoked session %s of user %s: refresh token was reused from %s\n", session.SessionId, session.UserId, ip)
+}
+
+// LogoutHandler revokes the current session and removes the cookies.
+func LogoutHandler(c *gin.Context) {
+	now := time.Now()
+	if claims, err := JWTAuthMiddleware.GetClaimsFromJWT(c); err == nil && claims["sid"] != nil {
+		models.DB.Model(&models.Session{}).Where("session_id = ? AND revoked_at IS NULL", claims["sid"]).Update("revoked_at", now)
+	} else if refreshToken := readRefreshToken(c); refreshToken != "" {
+		models.DB.Model(&models.Session{}).Where("refresh_token_hash = ? AND revoked_at IS NULL", models.HashSecret(refreshToken)).Update("revoked_at", now)
+	}
+
+	setRefreshCookie(c, "", -1)
+	JWTAuthMiddleware.LogoutHandler(c)
+}
+
+// SessionWithCurrent is a session of the logged-in user, marking the session the request was made with.
+type SessionWithCurrent struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// FindSessions lists the active sessions of the logged-in user, most recently used first.
+func FindSessions(c *gin.Context) {
+	var sessions []models.Session
+	claims := jwt.ExtractClaims(c)
+
+	models.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", claims["userId"], time.Now()).Order("last_used_at DESC").Find(&sessions)
+
+	result := make([]SessionWithCurrent, len(sessions))
+	for i, v := range sessions {
+		result[i] = SessionWithCurrent{Session: v, Current: v.SessionId.String() == claims["sid"]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// RevokeSession revokes a session of the logged-in user.
+func RevokeSession(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+
+	result := models.DB.Model(&models.Session{}).Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), claims["userId"]).Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+// RevokeAllSessions revokes all sessions of the logged-in user, including the current one.
+func RevokeAllSessions(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+
+	if err := RevokeUserSessions(uuid.MustParse(claims["userId"].(string))); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setRefreshCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+// RevokeUserSessions revokes all sessions of the user, so they are logged out everywhere once their access tokens are
+// checked again.
+func RevokeUserSessions(userId uuid.UUID) error {
+	err := models.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userId).Update("revoked_at", time.Now()).Error
+	if err == nil {
+		log.Printf("Revoked all sessions of user %s\n", userId)
+	}
+	return err
+}
+
+// RevokeOtherSessions revokes all sessions of the user except the one the request was made with, e.g. after the
+// password was changed.
+func RevokeOtherSessions(c *gin.Context, userId uuid.UUID) error {
+	query := models.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userId)
+	if sessionId, ok := jwt.ExtractClaims(c)["sid"].(string); ok && sessionId != "" {
+		query = query.Where("session_id <> ?", sessionId)
+	}
+
+	err := query.Update("revoked_at", time.Now()).Error
+	if err == nil {
+		log.Printf("Revoked the other sessions of user %s\n", userId)
+	}
+	return err
+}
+
+// IsSessionFresh reports whether the session of the request was created by a login within FRESH_SESSION_MAX_AGE
+// (default 5m). Refreshing a session does not make it fresh again.
+func IsSessionFresh(c *gin.Context) bool {
+	sessionId, ok := jwt.ExtractClaims(c)["sid"].(string)
+	if !ok {
+		return false
+	}
+
+	var session models.Session
+	if err := models.DB.Where("session_id = ?", sessionId).First(&session).Error; err != nil || !session.IsActive() {
+		return false
+	}
+	return time.Since(session.CreatedAt) < libs.GetEnvDuration("FRESH_SESSION_MAX_AGE", 5*time.Minute)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"metalab/metadrinks/models"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+type TokenLoginForm struct {
+	Uid string `form:"uid" json:"uid" binding:"required"`
+}
+
+var ErrUnknownToken = errors.New("unknown token")
+
+// AuthenticateToken returns the user the NFC/RFID tag belongs to. Unknown tags are recorded in the audit trail and
+// throttled per ip address like failed logins, so uids cannot be brute-forced. Detail tells where the tag was used.
+func AuthenticateToken(uid string, ip string, detail string) (*models.User, error) {
+	key := ipKey(ip)
+	if err := limiter.check(key); err != nil {
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginThrottled, IpAddress: ip, Detail: detail})
+		return nil, err
+	}
+
+	user, err := models.FindUserByTokenUid(uid)
+	if err != nil {
+		log.Printf("Failed token authentication (%s): %v\n", detail, err)
+		limiter.fail(key)
+		models.RecordAuditEvent(&models.AuditEvent{Type: models.AuditEventLoginFailed, IpAddress: ip, Detail: detail + ": unknown token"})
+		return nil, ErrUnknownToken
+	}
+
+	return user, nil
+}
+
+// TokenLoginHandler logs in the user the tapped NFC/RFID tag belongs to.
+func TokenLoginHandler(c *gin.Context) {
+	var form TokenLoginForm
+	if err := c.ShouldBind(&form); err != nil {
+		unauthorized()(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := AuthenticateToken(form.Uid, c.ClientIP(), "token login")
+	if err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			unauthorized()(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		unauthorized()(c, http.StatusUnauthorized, jwt.ErrFailedAuthentication.Error())
+		return
+	}
+
+	issueSession(c, user)
+}
@@ -5,7 +5,16 @@ import (
 )
 
 func RegisterRoutesAuth(r *gin.RouterGroup) {
-	r.POST("/login", JWTAuthMiddleware.LoginHandler)
-	r.POST("/logout", JWTAuthMiddleware.LogoutHandler)
-	r.GET("/refresh", JWTAuthMiddleware.RefreshHandler)
+	r.POST("/login", LoginHandler)
+	r.POST("/pin-login", PinLoginHandler)
+	r.POST("/token-login", RequireDevice(), TokenLoginHandler)
+	r.GET("/oidc/login", OidcLoginHandler)
+	r.GET("/oidc/callback", OidcCallbackHandler)
+	r.DELETE("/oidc/link", JWTAuthMiddleware.MiddlewareFunc(), UnlinkOidc)
+	r.POST("/logout", LogoutHandler)
+	r.GET("/refresh", RefreshHandler)
+	r.POST("/refresh", RefreshHandler)
+	r.GET("/sessions", JWTAuthMiddleware.MiddlewareFunc(), FindSessions)
+	r.DELETE("/sessions", JWTAuthMiddleware.MiddlewareFunc(), RevokeAllSessions)
+	r.DELETE("/sessions/:id", JWTAuthMiddleware.MiddlewareFunc(), RevokeSession)
 }
@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+)
+
+// StartReconciliation starts a background worker which resolves card purchases whose webhook never arrived. Every
+// RECONCILE_INTERVAL (default 1m) it queries the payment provider for purchases that are pending for longer than
+// RECONCILE_STALE_AFTER (default 2m). Purchases that are still pending after PENDING_TIMEOUT (default 30m) are
+// cancelled.
+func StartReconciliation() {
+	interval := libs.GetEnvDuration("RECONCILE_INTERVAL", time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ReconcilePendingPurchases()
+		}
+	}()
+	fmt.Printf("[INFO] Reconciliation: Checking pending card purchases every %s\n", interval)
+}
+
+// ReconcilePendingPurchases resolves all stale pending card purchases once.
+func ReconcilePendingPurchases() {
+	staleAfter := libs.GetEnvDuration("RECONCILE_STALE_AFTER", 2*time.Minute)
+	timeout := libs.GetEnvDuration("PENDING_TIMEOUT", 30*time.Minute)
+
+	var purchases []models.Purchase
+	if err := models.DB.Where("payment_type = ? AND transaction_status = ? AND created_at < ?", models.PaymentTypeCard, sumupmodels.TransactionFullStatusPending, time.Now().Add(-staleAfter)).Find(&purchases).Error; err != nil {
+		fmt.Printf("[ERROR] Reconciliation: Error finding pending purchases: %s\n", err.Error())
+		return
+	}
+
+	for _, v := range purchases {
+		transaction, err := libs.Provider.GetTransaction(v.ClientTransactionId)
+		if err != nil {
+			fmt.Printf("[ERROR] Reconciliation: Error getting transaction %s: %s\n", v.ClientTransactionId, err.Error())
+		} else if err := CheckTransactionAmount(&v, transaction); err != nil {
+			fmt.Printf("[ERROR] Reconciliation: Rejected transaction %s of purchase %s: %s\n", v.ClientTransactionId, v.PurchaseId, err.Error())
+		} else if transaction.Status != sumupmodels.TransactionFullStatusPending {
+			if _, err := UpdateTransactionStatus(v.ClientTransactionId, transaction.Status, transaction.TransactionId, models.PurchaseStatusSourceReconciliation, ""); err != nil {
+				fmt.Printf("[ERROR] Reconciliation: Error updating purchase %s: %s\n", v.PurchaseId, err.Error())
+			} else {
+				fmt.Printf("[INFO] Reconciliation: Purchase %s is %s\n", v.PurchaseId, transaction.Status)
+			}
+			continue
+		}
+
+		if time.Since(v.CreatedAt) > timeout {
+			if _, err := UpdateTransactionStatus(v.ClientTransactionId, sumupmodels.TransactionFullStatusCancelled, "", models.PurchaseStatusSourceReconciliation, "expired after "+timeout.String()); err != nil {
+				fmt.Printf("[ERROR] Reconciliation: Error expiring purchase %s: %s\n", v.PurchaseId, err.Error())
+			} else {
+				fmt.Printf("[INFO] Reconciliation: Purchase %s expired after %s and was cancelled\n", v.PurchaseId, timeout)
+			}
+		}
+	}
+}
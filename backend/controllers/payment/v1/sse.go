@@ -1,12 +1,14 @@
 package v1
 
 import (
+	"encoding/json"
 	"io"
 	"log"
 
 	sumupmodels "metalab/metadrinks/models/sumup"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type Event struct {
@@ -70,6 +72,8 @@ type SSENotificationType string
 const (
 	SSENotificationContentUpdate     string = "content_update"
 	SSENotificationTransactionUpdate string = "transaction_update"
+	SSENotificationStockAlert        string = "stock_alert"
+	SSENotificationBalanceTransfer   string = "balance_transfer"
 )
 
 type SSENotificationTransactionUpdatePayload struct {
@@ -77,8 +81,25 @@ type SSENotificationTransactionUpdatePayload struct {
 	TransactionStatus   sumupmodels.TransactionFullStatus `json:"transaction_status"`
 }
 
+type SSENotificationStockAlertPayload struct {
+	ItemId            uuid.UUID `json:"item_id"`
+	Name              string    `json:"name"`
+	Stock             int       `json:"stock"`
+	LowStockThreshold int       `json:"low_stock_threshold"`
+}
+
+type SSENotificationBalanceTransferPayload struct {
+	SenderId    uuid.UUID `json:"sender_id"`
+	SenderName  string    `json:"sender_name"`
+	RecipientId uuid.UUID `json:"recipient_id"`
+	Amount      uint      `json:"amount"`
+	Note        string    `json:"note,omitempty"`
+}
+
 type SSENotificationPayload struct {
 	TransactionPayload *SSENotificationTransactionUpdatePayload
+	StockAlertPayload  *SSENotificationStockAlertPayload
+	TransferPayload    *SSENotificationBalanceTransferPayload
 }
 
 func (Stream *Event) SendMessage(message string) {
@@ -87,6 +108,17 @@ func (Stream *Event) SendMessage(message string) {
 	}()
 }
 
+// SendNotification marshals the notification and sends it to all connected clients.
+func (Stream *Event) SendNotification(notification SSENotification) error {
+	notificationJSON, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	Stream.SendMessage(string(notificationJSON))
+	return nil
+}
+
 func SSEHeadersMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Content-Type", "text/event-stream")
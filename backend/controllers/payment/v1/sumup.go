@@ -1,11 +1,11 @@
 package v1
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"metalab/metadrinks/controllers/auth"
 	"metalab/metadrinks/libs"
 	"metalab/metadrinks/models"
 	sumupmodels "metalab/metadrinks/models/sumup"
@@ -41,16 +41,16 @@ func CreateReader(c *gin.Context) {
 		return
 	}
 
-	reader, err := libs.SumupClient.Readers.Create(context.Background(), *libs.SumupAccount.MerchantProfile.MerchantCode, readers.CreateReaderBody{Name: input.Name, PairingCode: input.PairingCode})
+	reader, err := libs.Provider.PairReader((*string)(input.Name), string(input.PairingCode))
 	if err != nil {
 		fmt.Printf("error while creating reader: %s\n", err.Error())
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	dbReader := sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(reader.Id), Name: sumupmodels.ReaderName(reader.Name), Status: sumupmodels.ReaderStatus(reader.Status), Device: sumupmodels.ReaderDevice{Identifier: reader.Device.Identifier, Model: sumupmodels.ReaderDeviceModel(reader.Device.Model)}, CreatedAt: reader.CreatedAt, UpdatedAt: reader.UpdatedAt}
+	dbReader := *reader
 
-	result, err := libs.InitiallyCheckIfReaderIsReady(string(reader.Id)) // polls the reader a few times to see if it is ready
+	result, err := libs.InitiallyCheckIfReaderIsReady(string(reader.ReaderId)) // polls the reader a few times to see if it is ready
 	if err != nil {
 		fmt.Printf("error while checking reader status: %s\n", err.Error())
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -89,7 +89,7 @@ func FindReaders(c *gin.Context) {
 }
 
 func FindApiReaders(c *gin.Context) {
-	response, err := libs.SumupClient.Readers.List(context.Background(), *libs.SumupAccount.MerchantProfile.MerchantCode)
+	response, err := libs.Provider.ListReaders()
 	if err != nil {
 		fmt.Printf("error finding reader by name: %s\n", err.Error())
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -159,7 +159,7 @@ type TerminateReaderInput struct {
 // TerminateReaderCheckout godoc
 //
 //	@Summary		Terminate reader checkout
-//	@Description	Stops the running reader checkout
+//	@Description	Stops the running reader checkout - only possible from registered kiosks, defaults to the reader of the kiosk
 //	@Tags			sumup
 //	@Accept			json
 //	@Produce		json
@@ -176,6 +176,10 @@ func TerminateReaderCheckout(c *gin.Context) {
 		return
 	}
 
+	if device := auth.CurrentDevice(c); device != nil && input.ReaderId == "" && input.ReaderName == "" {
+		input.ReaderId = device.DefaultReaderId
+	}
+
 	if input.ReaderId == "" && input.ReaderName == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "reader id/name missing"})
 		return
@@ -189,14 +193,14 @@ func TerminateReaderCheckout(c *gin.Context) {
 			return
 		}
 
-		terminateErr := libs.SumupClient.Readers.TerminateCheckout(context.Background(), *libs.SumupAccount.MerchantProfile.MerchantCode, string(dbReader.ReaderId)) // uses reader id from db, retrieved from name
+		terminateErr := libs.Provider.TerminateReaderCheckout(string(dbReader.ReaderId)) // uses reader id from db, retrieved from name
 		if terminateErr != nil {
 			fmt.Printf("error while terminating checkout by name: %s\n", terminateErr.Error())
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": terminateErr.Error()})
 			return
 		}
 	} else if input.ReaderId != "" && input.ReaderName == "" { // name undefined, id defined
-		terminateErr := libs.SumupClient.Readers.TerminateCheckout(context.Background(), *libs.SumupAccount.MerchantProfile.MerchantCode, input.ReaderId) // uses reader id from input
+		terminateErr := libs.Provider.TerminateReaderCheckout(input.ReaderId) // uses reader id from input
 		if terminateErr != nil {
 			fmt.Printf("error while terminating checkout by id: %s\n", terminateErr.Error())
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": terminateErr.Error()})
@@ -249,7 +253,7 @@ func UnlinkReader(c *gin.Context) {
 			return
 		}
 
-		unlinkErr := libs.SumupClient.Readers.DeleteReader(context.Background(), *libs.SumupAccount.MerchantProfile.MerchantCode, readers.ReaderId(dbReader.ReaderId))
+		unlinkErr := libs.Provider.UnpairReader(string(dbReader.ReaderId))
 		if unlinkErr != nil {
 			fmt.Printf("error while unlinking reader by name: %s\n", unlinkErr.Error())
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": unlinkErr.Error()})
@@ -261,7 +265,7 @@ func UnlinkReader(c *gin.Context) {
 			return
 		}
 	} else if input.ReaderId != "" && input.ReaderName == "" { // name undefined
-		unlinkErr := libs.SumupClient.Readers.DeleteReader(context.Background(), *libs.SumupAccount.MerchantProfile.MerchantCode, readers.ReaderId(input.ReaderId))
+		unlinkErr := libs.Provider.UnpairReader(input.ReaderId)
 		if unlinkErr != nil {
 			fmt.Printf("error while unlinking reader by id: %s\n", unlinkErr.Error())
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": unlinkErr.Error()})
@@ -285,12 +289,17 @@ func UnlinkReader(c *gin.Context) {
 // GetIncomingWebhook godoc
 //
 //	@Summary		Get incoming webhook
-//	@Description	Processes the incoming sumup webhook
+//	@Description	Processes the incoming sumup webhook - the transaction status is verified with the payment provider, the status in the webhook body is ignored
 //	@Tags			sumup
 //	@Accept			json
 //	@Produce		json
 //	@Success		200
+//	@Failure		400
+//	@Failure		404	"unknown client transaction id"
+//	@Failure		409	"invalid transaction status transition"
+//	@Failure		409	"amount of the transaction does not match the purchase"
 //	@Failure		500
+//	@Failure		502	"could not verify transaction with the payment provider"
 //
 //	@Param			webhook	body	sumupmodels.ReaderCheckoutStatusChange	true	"Webhook data"
 //
@@ -299,32 +308,31 @@ func GetIncomingWebhook(c *gin.Context) {
 	// After receiving a webhook call, your application must always verify if the event really took place, by calling a relevant SumUp's API.
 	var input sumupmodels.ReaderCheckoutStatusChange
 	if err := c.ShouldBindJSON(&input); err != nil {
+		fmt.Printf("rejected sumup webhook from %s: %s\n", c.ClientIP(), err.Error())
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	insertData := models.Purchase{TransactionStatus: input.Payload.Status}
-	fmt.Printf("incoming sumup webhook: %v", input.Payload)
-
-	models.DB.Where("client_transaction_id = ?", input.Payload.ClientTransactionId).Updates(insertData)
+	fmt.Printf("incoming sumup webhook: %v\n", input.Payload)
 
-	notification := SSENotification{
-		NotificationType: SSENotificationType(SSENotificationTransactionUpdate),
-		NotificationData: SSENotificationPayload{
-			TransactionPayload: &SSENotificationTransactionUpdatePayload{
-				ClientTransactionId: input.Payload.TransactionId,
-				TransactionStatus:   input.Payload.Status,
-			},
-		},
-	}
-
-	notificationJSON, err := json.Marshal(notification)
+	purchase, err := VerifyTransaction(input.Payload.ClientTransactionId, models.PurchaseStatusSourceWebhook)
 	if err != nil {
-		fmt.Printf("error marshalling notification: %s\n", err.Error())
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to process notification"})
+		fmt.Printf("rejected sumup webhook from %s for client transaction id %q: %s\n", c.ClientIP(), input.Payload.ClientTransactionId, err.Error())
+		switch {
+		case errors.Is(err, ErrUnknownTransaction):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrAmountMismatch):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrVerificationFailed):
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	if purchase.TransactionStatus != input.Payload.Status {
+		fmt.Printf("sumup webhook for client transaction id %q reported status %s, but the verified status is %s\n", input.Payload.ClientTransactionId, input.Payload.Status, purchase.TransactionStatus)
+	}
 
-	Stream.SendMessage(string(notificationJSON))
 	c.JSON(http.StatusOK, gin.H{"data": "success"})
 }
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownTransaction = errors.New("unknown client transaction id")
+	ErrInvalidTransition  = models.ErrInvalidTransition
+	ErrVerificationFailed = errors.New("could not verify transaction with the payment provider")
+	ErrAmountMismatch     = errors.New("amount of the transaction does not match the purchase")
+)
+
+// VerifyTransaction fetches the card transaction from the payment provider and applies its status to the purchase.
+// The status is never taken from the caller, so forged webhooks cannot change a purchase, and transactions whose
+// amount differs from the purchase are rejected.
+func VerifyTransaction(clientTransactionId string, source models.PurchaseStatusSource) (*models.Purchase, error) {
+	var purchase models.Purchase
+	if clientTransactionId == "" {
+		return nil, ErrUnknownTransaction
+	}
+	if err := models.DB.Where("client_transaction_id = ? AND payment_type = ?", clientTransactionId, models.PaymentTypeCard).First(&purchase).Error; err != nil {
+		return nil, ErrUnknownTransaction
+	}
+
+	transaction, err := libs.Provider.GetTransaction(clientTransactionId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrVerificationFailed, err.Error())
+	}
+	if err := CheckTransactionAmount(&purchase, transaction); err != nil {
+		return nil, err
+	}
+
+	return UpdateTransactionStatus(clientTransactionId, transaction.Status, transaction.TransactionId, source, "")
+}
+
+// CheckTransactionAmount returns ErrAmountMismatch if the provider charged a different amount than the purchase and
+// its top-up cost, or charged it in another currency.
+func CheckTransactionAmount(purchase *models.Purchase, transaction *libs.ProviderTransaction) error {
+	if transaction.Currency != libs.PaymentCurrency {
+		return fmt.Errorf("%w: expected %s, provider reported %q", ErrAmountMismatch, libs.PaymentCurrency, transaction.Currency)
+	}
+	if expected := purchase.FinalCost + purchase.TopUpAmount; transaction.Amount != expected {
+		return fmt.Errorf("%w: expected %d cents, provider reported %d cents", ErrAmountMismatch, expected, transaction.Amount)
+	}
+	return nil
+}
+
+// UpdateTransactionStatus moves a card purchase to a new status through models.TransitionPurchase. Repeating the
+// current status is a no-op. Only pending purchases can be moved, other transitions return ErrInvalidTransition.
+// Connected clients are notified about every change.
+func UpdateTransactionStatus(clientTransactionId string, status sumupmodels.TransactionFullStatus, transactionId string, source models.PurchaseStatusSource, note string) (*models.Purchase, error) {
+	var purchase models.Purchase
+	changed := false
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").Where("client_transaction_id = ? AND payment_type = ?", clientTransactionId, models.PaymentTypeCard).First(&purchase).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownTransaction
+			}
+			return err
+		}
+
+		if purchase.TransactionStatus == status {
+			return nil
+		}
+		// the provider only decides about pending purchases, everything after that is up to the backend
+		if purchase.TransactionStatus != sumupmodels.TransactionFullStatusPending {
+			return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, purchase.TransactionStatus, status)
+		}
+		if transactionId != "" {
+			purchase.TransactionId = transactionId
+			if err := tx.Model(&purchase).Update("transaction_id", transactionId).Error; err != nil {
+				return err
+			}
+		}
+		changed = true
+		return models.TransitionPurchase(tx, &purchase, status, source, uuid.Nil, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		SendTransactionUpdate(&purchase)
+	}
+	return &purchase, nil
+}
+
+// SendTransactionUpdate notifies connected clients about the current status of the purchase.
+func SendTransactionUpdate(purchase *models.Purchase) {
+	notification := SSENotification{
+		NotificationType: SSENotificationType(SSENotificationTransactionUpdate),
+		NotificationData: SSENotificationPayload{
+			TransactionPayload: &SSENotificationTransactionUpdatePayload{
+				ClientTransactionId: purchase.ClientTransactionId,
+				TransactionStatus:   purchase.TransactionStatus,
+			},
+		},
+	}
+
+	if err := Stream.SendNotification(notification); err != nil {
+		fmt.Printf("error marshalling notification: %s\n", err.Error())
+	}
+}
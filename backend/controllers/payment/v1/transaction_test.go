@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"errors"
+	"testing"
+
+	"metalab/metadrinks/libs"
+	"metalab/metadrinks/models"
+)
+
+func TestCheckTransactionAmount(t *testing.T) {
+	tests := []struct {
+		name        string
+		finalCost   uint
+		topUpAmount uint
+		amount      uint
+		currency    string
+		wantErr     bool
+	}{
+		{"purchase", 250, 0, 250, libs.PaymentCurrency, false},
+		{"top-up", 0, 2000, 2000, libs.PaymentCurrency, false},
+		{"purchase with top-up", 250, 2000, 2250, libs.PaymentCurrency, false},
+		{"underpaid purchase", 250, 0, 100, libs.PaymentCurrency, true},
+		{"underpaid top-up", 0, 2000, 1, libs.PaymentCurrency, true},
+		{"top-up missing", 250, 2000, 250, libs.PaymentCurrency, true},
+		{"overpaid", 250, 0, 300, libs.PaymentCurrency, true},
+		{"no amount reported", 250, 0, 0, libs.PaymentCurrency, true},
+		{"other currency", 250, 0, 250, "USD", true},
+		{"no currency reported", 250, 0, 250, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchase := models.Purchase{FinalCost: tt.finalCost, TopUpAmount: tt.topUpAmount}
+			transaction := libs.ProviderTransaction{Amount: tt.amount, Currency: tt.currency}
+
+			err := CheckTransactionAmount(&purchase, &transaction)
+			if tt.wantErr && !errors.Is(err, ErrAmountMismatch) {
+				t.Errorf("expected ErrAmountMismatch, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
@@ -2,19 +2,22 @@ package v1
 
 import (
 	"metalab/metadrinks/controllers/auth"
+	"metalab/metadrinks/models"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RegisterRoutesV1 registers the payment routes. Linking and unlinking readers needs the readers.manage permission,
+// kiosk-only routes need a device credential and the callback is verified with the payment provider.
 func RegisterRoutesV1(r *gin.RouterGroup) {
 	r.POST("/callback", GetIncomingWebhook)
-	r.GET("/events", SSEHeadersMiddleware(), Stream.ServeHTTP())
+	r.GET("/events", auth.RequireDevice(), SSEHeadersMiddleware(), Stream.ServeHTTP())
 
 	re := r.Group("readers")
 	re.GET("/", FindReaders)
 	re.GET("/:id", FindReader)
-	re.GET("/api", auth.JWTAuthMiddleware.MiddlewareFunc(), FindApiReaders)
-	re.POST("/link", auth.JWTAuthMiddleware.MiddlewareFunc(), CreateReader)
-	re.DELETE("/terminate", TerminateReaderCheckout)
-	re.DELETE("/unlink", auth.JWTAuthMiddleware.MiddlewareFunc(), UnlinkReader)
+	re.GET("/api", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionReadersManage), FindApiReaders)
+	re.POST("/link", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionReadersManage), CreateReader)
+	re.DELETE("/terminate", auth.RequireDevice(), TerminateReaderCheckout)
+	re.DELETE("/unlink", auth.JWTAuthMiddleware.MiddlewareFunc(), auth.RequirePermission(models.PermissionReadersManage), UnlinkReader)
 }
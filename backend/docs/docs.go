@@ -19,9 +19,78 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/audit-events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists failed and throttled logins, lockouts and unlocks, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find audit events",
+                "parameters": [
+                    {
+                        "enum": [
+                            "login_failed",
+                            "login_throttled",
+                            "account_locked",
+                            "account_unlocked"
+                        ],
+                        "type": "string",
+                        "description": "Event type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of events",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
         "/callback": {
             "post": {
-                "description": "Processes the incoming sumup webhook",
+                "description": "Processes the incoming sumup webhook - the transaction status is verified with the payment provider, the status in the webhook body is ignored",
                 "consumes": [
                     "application/json"
                 ],
@@ -47,15 +116,27 @@ const docTemplate = `{
                     "200": {
                         "description": "OK"
                     },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "unknown client transaction id"
+                    },
+                    "409": {
+                        "description": "amount of the transaction does not match the purchase"
+                    },
                     "500": {
                         "description": "Internal Server Error"
+                    },
+                    "502": {
+                        "description": "could not verify transaction with the payment provider"
                     }
                 }
             }
         },
-        "/items": {
+        "/categories": {
             "get": {
-                "description": "get items",
+                "description": "get categories in sort order",
                 "consumes": [
                     "application/json"
                 ],
@@ -63,16 +144,16 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "items"
+                    "categories"
                 ],
-                "summary": "Find items",
+                "summary": "Find categories",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Item"
+                                "$ref": "#/definitions/models.Category"
                             }
                         }
                     },
@@ -87,7 +168,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "create new item",
+                "description": "create new item category",
                 "consumes": [
                     "application/json"
                 ],
@@ -95,17 +176,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "items"
+                    "categories"
                 ],
-                "summary": "Create item",
+                "summary": "Create category",
                 "parameters": [
                     {
-                        "description": "Create item",
-                        "name": "item",
+                        "description": "Create category",
+                        "name": "category",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/v1.CreateItemInput"
+                            "$ref": "#/definitions/v1.CreateCategoryInput"
                         }
                     }
                 ],
@@ -113,9 +194,12 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Item"
+                            "$ref": "#/definitions/models.Category"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request"
+                    },
                     "401": {
                         "description": "Unauthorized"
                     },
@@ -125,9 +209,66 @@ const docTemplate = `{
                 }
             }
         },
-        "/items/{id}": {
+        "/categories/sort": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "sets the sort index of multiple categories at once",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Sort categories",
+                "parameters": [
+                    {
+                        "description": "New sort order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.SortInput"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Category"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/categories/{id}": {
             "get": {
-                "description": "get specific item",
+                "description": "get specific category",
                 "consumes": [
                     "application/json"
                 ],
@@ -135,13 +276,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "items"
+                    "categories"
                 ],
-                "summary": "Find item",
+                "summary": "Find category",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Item UUID",
+                        "description": "Category UUID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -151,7 +292,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Item"
+                            "$ref": "#/definitions/models.Category"
                         }
                     },
                     "404": {
@@ -168,7 +309,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "update specific item",
+                "description": "update specific category",
                 "consumes": [
                     "application/json"
                 ],
@@ -176,25 +317,37 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "items"
+                    "categories"
                 ],
-                "summary": "Update item",
+                "summary": "Update category",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Item UUID",
+                        "description": "Category UUID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Update category",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UpdateCategoryInput"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Item"
+                            "$ref": "#/definitions/models.Category"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request"
+                    },
                     "401": {
                         "description": "Unauthorized"
                     },
@@ -212,7 +365,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "delete specific item",
+                "description": "delete specific category - its items are kept without a category",
                 "consumes": [
                     "application/json"
                 ],
@@ -220,13 +373,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "items"
+                    "categories"
                 ],
-                "summary": "Delete item",
+                "summary": "Delete category",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Item UUID",
+                        "description": "Category UUID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -251,14 +404,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/purchases": {
+        "/devices": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "find purchases - only returns purchases of the currently logged-in user",
+                "description": "lists all registered kiosks",
                 "consumes": [
                     "application/json"
                 ],
@@ -266,16 +419,16 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "purchases"
+                    "devices"
                 ],
-                "summary": "Find purchases",
+                "summary": "Find devices",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Purchase"
+                                "$ref": "#/definitions/models.Device"
                             }
                         }
                     },
@@ -293,7 +446,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "create new purchase - only item id is needed in initial creation request",
+                "description": "registers a kiosk - the returned credential has to be sent by the kiosk in the X-Device-Token header and is only shown once",
                 "consumes": [
                     "application/json"
                 ],
@@ -301,17 +454,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "purchases"
+                    "devices"
                 ],
-                "summary": "Create purchase",
+                "summary": "Register device",
                 "parameters": [
                     {
-                        "description": "Create purchase",
-                        "name": "purchase",
+                        "description": "Register device",
+                        "name": "device",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/v1.CreatePurchaseInput"
+                            "$ref": "#/definitions/v1.CreateDeviceInput"
                         }
                     }
                 ],
@@ -319,32 +472,24 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Purchase"
+                            "$ref": "#/definitions/v1.DeviceWithCredential"
                         }
                     },
                     "400": {
-                        "description": "final cost exceeds maximum allowed value"
+                        "description": "Bad Request"
                     },
                     "401": {
                         "description": "Unauthorized"
                     },
-                    "403": {
-                        "description": "not enough balance"
-                    },
                     "500": {
-                        "description": "error while creating reader checkout"
+                        "description": "Internal Server Error"
                     }
                 }
             }
         },
-        "/purchases/{id}": {
+        "/devices/me": {
             "get": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "find purchase - only returns purchases of the currently logged-in user",
+                "description": "returns the kiosk the request was made from, e.g. to look up its default reader",
                 "consumes": [
                     "application/json"
                 ],
@@ -352,40 +497,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "purchases"
-                ],
-                "summary": "Find purchase",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Purchase UUID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "devices"
                 ],
+                "summary": "Find current device",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Purchase"
+                            "$ref": "#/definitions/models.Device"
                         }
                     },
                     "401": {
                         "description": "Unauthorized"
-                    },
-                    "404": {
-                        "description": "Not Found"
-                    },
-                    "500": {
-                        "description": "Internal Server Error"
                     }
                 }
             }
         },
-        "/readers": {
-            "get": {
-                "description": "Returns all readers",
+        "/devices/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "removes a kiosk - its credential stops working immediately",
                 "consumes": [
                     "application/json"
                 ],
@@ -393,28 +528,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sumup"
+                    "devices"
+                ],
+                "summary": "Delete device",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Device UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Find readers",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "success",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Reader"
-                            }
+                            "type": "string"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
                 }
-            }
-        },
-        "/readers/link": {
-            "post": {
-                "description": "Creates and links new reader",
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "renames a kiosk or changes its default reader",
                 "consumes": [
                     "application/json"
                 ],
@@ -422,17 +572,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sumup"
+                    "devices"
                 ],
-                "summary": "Create reader",
+                "summary": "Update device",
                 "parameters": [
                     {
-                        "description": "Create reader",
-                        "name": "user",
+                        "type": "string",
+                        "description": "Device UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update device",
+                        "name": "device",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/readers.CreateReaderBody"
+                            "$ref": "#/definitions/v1.UpdateDeviceInput"
                         }
                     }
                 ],
@@ -440,21 +597,32 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Reader"
+                            "$ref": "#/definitions/models.Device"
                         }
                     },
                     "400": {
                         "description": "Bad Request"
                     },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
                 }
             }
         },
-        "/readers/terminate": {
-            "delete": {
-                "description": "Stops the running reader checkout",
+        "/devices/{id}/credential": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "replaces the credential of a kiosk - the old credential stops working immediately",
                 "consumes": [
                     "application/json"
                 ],
@@ -462,23 +630,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sumup"
+                    "devices"
                 ],
-                "summary": "Terminate reader checkout",
+                "summary": "Rotate device credential",
                 "parameters": [
                     {
-                        "description": "Terminate reader input",
-                        "name": "reader",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/v1.TerminateReaderInput"
-                        }
+                        "type": "string",
+                        "description": "Device UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.DeviceWithCredential"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
                     },
                     "500": {
                         "description": "Internal Server Error"
@@ -486,9 +661,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/readers/unlink": {
-            "delete": {
-                "description": "Unlinks the specified reader",
+        "/items": {
+            "get": {
+                "description": "get items in sort order - optionally filtered by category or grouped by category",
                 "consumes": [
                     "application/json"
                 ],
@@ -496,33 +671,51 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sumup"
+                    "items"
                 ],
-                "summary": "Unlink reader",
+                "summary": "Find items",
                 "parameters": [
                     {
-                        "description": "Unlink reader input",
-                        "name": "reader",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/v1.UnlinkReaderInput"
-                        }
+                        "type": "string",
+                        "description": "Category UUID",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "category"
+                        ],
+                        "type": "string",
+                        "description": "Set to 'category' to group the items by category",
+                        "name": "group",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "if grouped by category",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.ItemGroup"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
                     },
                     "500": {
                         "description": "Internal Server Error"
                     }
                 }
-            }
-        },
-        "/readers/{id}": {
-            "get": {
-                "description": "Returns specific reader",
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "create new item",
                 "consumes": [
                     "application/json"
                 ],
@@ -530,34 +723,39 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sumup"
+                    "items"
                 ],
-                "summary": "Find reader",
+                "summary": "Create item",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Reader UUID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Create item",
+                        "name": "item",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CreateItemInput"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Reader"
+                            "$ref": "#/definitions/models.Item"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
                 }
             }
         },
-        "/users": {
+        "/items/low-stock": {
             "get": {
-                "description": "Lists all users",
+                "description": "lists all items whose stock is below their low stock threshold, lowest stock first",
                 "consumes": [
                     "application/json"
                 ],
@@ -565,16 +763,16 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "items"
                 ],
-                "summary": "Find users",
+                "summary": "Find low stock items",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.User"
+                                "$ref": "#/definitions/models.Item"
                             }
                         }
                     },
@@ -582,9 +780,16 @@ const docTemplate = `{
                         "description": "Internal Server Error"
                     }
                 }
-            },
-            "post": {
-                "description": "creates a new user",
+            }
+        },
+        "/items/sort": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "sets the sort index of multiple items at once",
                 "consumes": [
                     "application/json"
                 ],
@@ -592,17 +797,20 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "items"
                 ],
-                "summary": "Create user",
+                "summary": "Sort items",
                 "parameters": [
                     {
-                        "description": "Create user",
-                        "name": "user",
+                        "description": "New sort order",
+                        "name": "order",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/v1.CreateUserInput"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.SortInput"
+                            }
                         }
                     }
                 ],
@@ -610,21 +818,30 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Item"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request"
                     },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
                 }
             }
         },
-        "/users/{id}": {
+        "/items/{id}": {
             "get": {
-                "description": "Returns specific user",
+                "description": "get specific item",
                 "consumes": [
                     "application/json"
                 ],
@@ -632,13 +849,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "items"
                 ],
-                "summary": "Find user",
+                "summary": "Find item",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User UUID",
+                        "description": "Item UUID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -648,102 +865,2739 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/models.Item"
                         }
                     },
+                    "404": {
+                        "description": "Not Found"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "gorm.DeletedAt": {
-            "type": "object",
-            "properties": {
-                "time": {
-                    "type": "string"
-                },
-                "valid": {
-                    "description": "Valid is true if Time is not NULL",
-                    "type": "boolean"
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "update specific item",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "items"
+                ],
+                "summary": "Update item",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Item UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Item"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
                 }
-            }
-        },
-        "models.Item": {
-            "type": "object",
-            "properties": {
-                "id": {
-                    "type": "string",
-                    "example": "00000000-0000-0000-0000-000000000000"
-                },
-                "image": {
-                    "type": "string",
-                    "default": "assets/empty.webp"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "integer"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "delete specific item",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "items"
+                ],
+                "summary": "Delete item",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Item UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
                 }
             }
         },
-        "models.Meta": {
-            "type": "object",
-            "additionalProperties": {}
-        },
-        "models.PaymentType": {
-            "type": "string",
-            "enum": [
-                "cash",
+        "/items/{id}/stock": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists the stock movements of an item, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "items"
+                ],
+                "summary": "Find stock movements",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Item UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of movements",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.StockMovement"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "changes the stock level of an item - restocks must be positive, breakages negative",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "items"
+                ],
+                "summary": "Create stock movement",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Item UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Create stock movement",
+                        "name": "movement",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CreateStockMovementInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.StockMovement"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "find purchases - only returns purchases of the currently logged-in user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Find purchases",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Purchase"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "create new purchase - only item id and quantity are needed for each purchased item. Guests can only buy at registered kiosks, which also provide the default reader for card payments. To top up the balance, set amount instead of items: card top-ups are credited once the card payment is successful, cash top-ups once an admin or trusted user confirmed them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Create purchase",
+                "parameters": [
+                    {
+                        "description": "Create purchase",
+                        "name": "purchase",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CreatePurchaseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "400": {
+                        "description": "top-ups cannot be paid with balance"
+                    },
+                    "401": {
+                        "description": "guest purchases are only possible at registered kiosks"
+                    },
+                    "403": {
+                        "description": "wrong pin"
+                    },
+                    "404": {
+                        "description": "item not found"
+                    },
+                    "409": {
+                        "description": "item is out of stock"
+                    },
+                    "429": {
+                        "description": "pin login is locked"
+                    },
+                    "500": {
+                        "description": "error while creating reader checkout"
+                    }
+                }
+            }
+        },
+        "/purchases/tap": {
+            "post": {
+                "description": "creates a purchase paid with the balance of the user the tapped NFC/RFID tag belongs to, without logging in - only possible from registered kiosks",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Tap and buy",
+                "parameters": [
+                    {
+                        "description": "Tap and buy",
+                        "name": "purchase",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.TapPurchaseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "valid device credential required"
+                    },
+                    "403": {
+                        "description": "wrong pin"
+                    },
+                    "404": {
+                        "description": "item not found"
+                    },
+                    "409": {
+                        "description": "item is out of stock"
+                    },
+                    "429": {
+                        "description": "too many failed login attempts"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/top-ups": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists the cash top-ups of all users which still have to be confirmed, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Find pending top-ups",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Purchase"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/voids": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists voided and refunded purchases of all users, newest first - use payment_type=cash\u0026unreconciled=true to get the cash that still has to be taken out of the drawer",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Find voided purchases",
+                "parameters": [
+                    {
+                        "enum": [
+                            "cash",
+                            "card",
+                            "balance"
+                        ],
+                        "type": "string",
+                        "description": "Payment type",
+                        "name": "payment_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return purchases that were not reconciled yet",
+                        "name": "unreconciled",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Purchase"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "find purchase including its status history - only returns purchases of the currently logged-in user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Find purchase",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Purchase UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/{id}/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "confirms that the cash of a pending cash top-up was received and credits the balance of the user - trusted users and users with the balances.manage permission can confirm top-ups, but only the latter their own",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Confirm top-up",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Purchase UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "top-ups cannot be confirmed by the same user"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "only pending cash top-ups can be confirmed"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/{id}/reconcile": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "marks the cash of a voided cash purchase as taken out of the drawer",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Reconcile voided purchase",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Purchase UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/{id}/status": {
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "resolves a pending purchase by hand, e.g. if the payment provider never reported its outcome - successful purchases can only be undone with a void",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Update purchase status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Purchase UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UpdatePurchaseStatusInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "invalid transaction status transition"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/purchases/{id}/void": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "undoes a successful purchase - buyers can void their own purchases within the void window, users with the purchases.manage permission any purchase at any time. Balance payments are credited back, card payments are refunded and cash payments are marked for drawer reconciliation. Card purchases are refund_pending while the refund is sent to the payment provider.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchases"
+                ],
+                "summary": "Void purchase",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Purchase UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Void purchase",
+                        "name": "void",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.VoidPurchaseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "void window has expired"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "409": {
+                        "description": "transfers cannot be voided"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    },
+                    "502": {
+                        "description": "error while refunding transaction"
+                    }
+                }
+            }
+        },
+        "/readers": {
+            "get": {
+                "description": "Returns all readers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sumup"
+                ],
+                "summary": "Find readers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Reader"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/readers/link": {
+            "post": {
+                "description": "Creates and links new reader",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sumup"
+                ],
+                "summary": "Create reader",
+                "parameters": [
+                    {
+                        "description": "Create reader",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/readers.CreateReaderBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Reader"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/readers/terminate": {
+            "delete": {
+                "description": "Stops the running reader checkout - only possible from registered kiosks, defaults to the reader of the kiosk",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sumup"
+                ],
+                "summary": "Terminate reader checkout",
+                "parameters": [
+                    {
+                        "description": "Terminate reader input",
+                        "name": "reader",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.TerminateReaderInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/readers/unlink": {
+            "delete": {
+                "description": "Unlinks the specified reader",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sumup"
+                ],
+                "summary": "Unlink reader",
+                "parameters": [
+                    {
+                        "description": "Unlink reader input",
+                        "name": "reader",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UnlinkReaderInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/readers/{id}": {
+            "get": {
+                "description": "Returns specific reader",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sumup"
+                ],
+                "summary": "Find reader",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Reader"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/reports/sales": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "sums up the sold quantity and revenue of every item per payment type for successful purchases in the given period - format=csv returns a csv file instead of json",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Export sales report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start of the period (RFC 3339 or YYYY-MM-DD), defaults to 30 days ago",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period (RFC 3339 or YYYY-MM-DD), defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "description": "Output format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/v1.SalesReportLine"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/roles": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists all roles with their permissions",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Find roles",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Role"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "creates a role with the given permissions",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Create role",
+                "parameters": [
+                    {
+                        "description": "Create role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.RoleInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/roles/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "replaces the name, description and permissions of a role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Update role",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Role UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.RoleInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Role"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "deletes a role and removes it from all users",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roles"
+                ],
+                "summary": "Delete role",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Role UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "description": "lists the id, name and image of all active users, most recently used first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PublicUser"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "post": {
+                "description": "creates a new user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create user",
+                "parameters": [
+                    {
+                        "description": "Create user",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CreateUserInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/deleted": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists all soft-deleted users, most recently deleted first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find deleted users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PrivateUser"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/details": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists all details of all users, including inactive ones",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find user details",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PrivateUser"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "returns the logged-in user with their current balance, flags and most recent purchases",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find current user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of recent purchases, defaults to 10",
+                        "name": "purchases",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/v1.CurrentUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "updates the name and image of the logged-in user - only the given fields are changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update current user",
+                "parameters": [
+                    {
+                        "description": "Update current user",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UpdateCurrentUserInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/me/image": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "sets the avatar of the logged-in user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update current user image",
+                "parameters": [
+                    {
+                        "description": "New image",
+                        "name": "image",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UpdateCurrentUserImageInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/me/password": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "changes the password of the logged-in user and logs out all their other sessions - the old password has to be given, unless a user created by the single sign-on sets their first password within FRESH_SESSION_MAX_AGE after logging in",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "description": "Change password",
+                        "name": "password",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.ChangePasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "log in again before setting a password"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/me/pin": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "sets the 4 to 6 digit pin of the logged-in user, which can be used to log in at the kiosk instead of the password - the current password has to be given, users without a password have to have logged in within FRESH_SESSION_MAX_AGE",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Set pin",
+                "parameters": [
+                    {
+                        "description": "Set pin",
+                        "name": "pin",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.SetPinInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "pin must consist of 4 to 6 digits"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "log in again before changing the pin"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "removes the pin of the logged-in user - the current password has to be given, users without a password have to have logged in within FRESH_SESSION_MAX_AGE",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete pin",
+                "parameters": [
+                    {
+                        "description": "Delete pin",
+                        "name": "pin",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.DeletePinInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "log in again before changing the pin"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/me/tokens": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists the NFC/RFID tags of the logged-in user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UserToken"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "registers an NFC/RFID tag for the logged-in user - only a hash of the uid is stored",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Register token",
+                "parameters": [
+                    {
+                        "description": "Register token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.CreateUserTokenInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "409": {
+                        "description": "token is already registered"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/me/tokens/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "removes an NFC/RFID tag of the logged-in user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/transfer": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "moves balance from the logged-in user to another user - both users get a transfer purchase in their purchase history and the recipient is notified over the event stream",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Transfer balance",
+                "parameters": [
+                    {
+                        "description": "Transfer balance",
+                        "name": "transfer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.TransferBalanceInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Purchase"
+                        }
+                    },
+                    "400": {
+                        "description": "cannot transfer balance to yourself"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "not enough balance"
+                    },
+                    "404": {
+                        "description": "recipient not found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}": {
+            "get": {
+                "description": "returns specific user - all details are only returned to the user themselves and to users with the users.manage permission, everyone else gets id, name and image",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "soft-deletes a user - their purchases and ledger are kept and the user can be restored",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "updates the name, image, flags and single sign-on link of a user - only the given fields are changed. Admins cannot remove their own admin flag or deactivate themselves.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update user",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UpdateUserInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "only admins can change the single sign-on link"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}/balance": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "adds change to the balance of a user (negative values remove balance) and records it as a correction in the ledger - also works for deleted users",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Correct user balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Balance correction",
+                        "name": "correction",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.UpdateUserBalanceInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BalanceTransaction"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}/credit-limit": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "sets how far the balance of a user may go below zero - null resets it to the global default",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Set user credit limit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Credit limit",
+                        "name": "limit",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.SetCreditLimitInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}/ledger": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lists the balance transactions of a user, newest first - only the user themselves and users with the balances.manage permission can see them",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find user ledger",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of transactions",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.BalanceTransaction"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "restores a soft-deleted user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Restore user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}/roles": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "replaces the roles of a user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Set user roles",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Roles",
+                        "name": "roles",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/v1.SetUserRolesInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/users/{id}/unlock": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "lifts the lockout of a user after too many failed password or pin logins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Unlock user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PrivateUser"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "gorm.DeletedAt": {
+            "type": "object",
+            "properties": {
+                "time": {
+                    "type": "string"
+                },
+                "valid": {
+                    "description": "Valid is true if Time is not NULL",
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "description": "the admin for events triggered by admins",
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.AuditEventType"
+                },
+                "user_id": {
+                    "description": "null if the username does not exist",
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditEventType": {
+            "type": "string",
+            "enum": [
+                "login_failed",
+                "login_throttled",
+                "account_locked",
+                "account_unlocked"
+            ],
+            "x-enum-varnames": [
+                "AuditEventLoginFailed",
+                "AuditEventLoginThrottled",
+                "AuditEventAccountLocked",
+                "AuditEventAccountUnlocked"
+            ]
+        },
+        "models.BalanceTransaction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "positive amounts add balance, negative amounts remove it",
+                    "type": "integer"
+                },
+                "balance_after": {
+                    "type": "integer"
+                },
+                "counterparty_id": {
+                    "description": "the other user of a transfer",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "purchase_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.BalanceTransactionType"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BalanceTransactionType": {
+            "type": "string",
+            "enum": [
+                "purchase",
+                "top_up",
+                "refund",
+                "transfer",
+                "correction"
+            ],
+            "x-enum-varnames": [
+                "BalanceTransactionTypePurchase",
+                "BalanceTransactionTypeTopUp",
+                "BalanceTransactionTypeRefund",
+                "BalanceTransactionTypeTransfer",
+                "BalanceTransactionTypeCorrection"
+            ]
+        },
+        "models.Category": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "00000000-0000-0000-0000-000000000000"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sort_index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Device": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "default_reader_id": {
+                    "description": "used for card payments of the kiosk if no reader is given",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_seen_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Item": {
+            "type": "object",
+            "properties": {
+                "category_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "00000000-0000-0000-0000-000000000000"
+                },
+                "image": {
+                    "type": "string",
+                    "default": "assets/empty.webp"
+                },
+                "low_stock_threshold": {
+                    "description": "a stock alert is sent once the stock drops below this, 0 disables alerts",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "sort_index": {
+                    "type": "integer"
+                },
+                "stock": {
+                    "description": "only changed through stock movements, may become negative",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Meta": {
+            "type": "object",
+            "additionalProperties": {}
+        },
+        "models.PaymentType": {
+            "type": "string",
+            "enum": [
+                "cash",
                 "card",
-                "balance"
+                "balance",
+                "transfer"
             ],
             "x-enum-varnames": [
                 "PaymentTypeCash",
                 "PaymentTypeCard",
-                "PaymentTypeBalance"
+                "PaymentTypeBalance",
+                "PaymentTypeTransfer"
+            ]
+        },
+        "models.Permission": {
+            "type": "string",
+            "enum": [
+                "items.manage",
+                "stock.manage",
+                "users.manage",
+                "balances.manage",
+                "purchases.manage",
+                "reports.export",
+                "readers.manage",
+                "devices.manage",
+                "roles.manage"
+            ],
+            "x-enum-comments": {
+                "PermissionBalancesManage": "correct balances, set credit limits, confirm top-ups and see ledgers",
+                "PermissionDevicesManage": "register kiosks",
+                "PermissionItemsManage": "create, edit and sort items and categories",
+                "PermissionPurchasesManage": "void any purchase, set purchase statuses and reconcile the cash drawer",
+                "PermissionReadersManage": "link and unlink card readers",
+                "PermissionReportsExport": "export sales reports",
+                "PermissionRolesManage": "edit roles and assign them to users",
+                "PermissionStockManage": "restock items and see their stock movements",
+                "PermissionUsersManage": "edit, delete and restore users and see their details"
+            },
+            "x-enum-descriptions": [
+                "create, edit and sort items and categories",
+                "restock items and see their stock movements",
+                "edit, delete and restore users and see their details",
+                "correct balances, set credit limits, confirm top-ups and see ledgers",
+                "void any purchase, set purchase statuses and reconcile the cash drawer",
+                "export sales reports",
+                "link and unlink card readers",
+                "register kiosks",
+                "edit roles and assign them to users"
+            ],
+            "x-enum-varnames": [
+                "PermissionItemsManage",
+                "PermissionStockManage",
+                "PermissionUsersManage",
+                "PermissionBalancesManage",
+                "PermissionPurchasesManage",
+                "PermissionReportsExport",
+                "PermissionReadersManage",
+                "PermissionDevicesManage",
+                "PermissionRolesManage"
             ]
         },
-        "models.Purchase": {
+        "models.PrivateUser": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "credit_limit": {
+                    "type": "integer"
+                },
+                "deleted_at": {
+                    "$ref": "#/definitions/gorm.DeletedAt"
+                },
+                "effective_credit_limit": {
+                    "type": "integer"
+                },
+                "has_pin": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image": {
+                    "type": "string"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "is_admin": {
+                    "type": "boolean"
+                },
+                "is_restricted": {
+                    "type": "boolean"
+                },
+                "is_trusted": {
+                    "type": "boolean"
+                },
+                "locked_until": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "oidc_linked": {
+                    "type": "boolean"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Role"
+                    }
+                },
+                "used_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PublicUser": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "image": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Purchase": {
+            "type": "object",
+            "properties": {
+                "client_transaction_id": {
+                    "type": "string"
+                },
+                "counterparty_id": {
+                    "description": "the other user of a transfer",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "description": "uuid of user, otherwise null uuid (for guests)",
+                    "type": "string"
+                },
+                "drawer_reconciled_at": {
+                    "description": "set once the cash of a voided cash purchase was taken out of the drawer",
+                    "type": "string"
+                },
+                "final_cost": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PurchaseItem"
+                    }
+                },
+                "payment_type": {
+                    "$ref": "#/definitions/models.PaymentType"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.TransactionFullStatus"
+                },
+                "status_history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PurchaseStatusChange"
+                    }
+                },
+                "top_up_amount": {
+                    "description": "added to the balance of the user once the purchase is successful",
+                    "type": "integer"
+                },
+                "transaction_id": {
+                    "description": "sumup transaction id, needed for refunds",
+                    "type": "string"
+                },
+                "void_reason": {
+                    "type": "string"
+                },
+                "voided_at": {
+                    "type": "string"
+                },
+                "voided_by": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PurchaseItem": {
             "type": "object",
             "properties": {
-                "client_transaction_id": {
+                "id": {
+                    "type": "string"
+                },
+                "item_id": {
+                    "type": "string"
+                },
+                "line_total": {
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 },
+                "quantity": {
+                    "type": "integer"
+                },
+                "unit_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PurchaseStatusChange": {
+            "type": "object",
+            "properties": {
                 "created_at": {
                     "type": "string"
                 },
                 "created_by": {
-                    "description": "uuid of user, otherwise null uuid (for guests)",
+                    "description": "null uuid for changes made by the payment provider",
                     "type": "string"
                 },
-                "final_cost": {
-                    "type": "integer"
+                "from": {
+                    "$ref": "#/definitions/models.TransactionFullStatus"
                 },
                 "id": {
                     "type": "string"
                 },
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Item"
-                    }
-                },
-                "payment_type": {
-                    "$ref": "#/definitions/models.PaymentType"
+                "note": {
+                    "type": "string"
                 },
-                "refund_amount": {
-                    "description": "adds balance to the user account",
-                    "type": "integer"
+                "source": {
+                    "$ref": "#/definitions/models.PurchaseStatusSource"
                 },
-                "status": {
+                "to": {
                     "$ref": "#/definitions/models.TransactionFullStatus"
                 }
             }
         },
+        "models.PurchaseStatusSource": {
+            "type": "string",
+            "enum": [
+                "checkout",
+                "webhook",
+                "reconciliation",
+                "void",
+                "admin",
+                "confirmation"
+            ],
+            "x-enum-varnames": [
+                "PurchaseStatusSourceCheckout",
+                "PurchaseStatusSourceWebhook",
+                "PurchaseStatusSourceReconciliation",
+                "PurchaseStatusSourceVoid",
+                "PurchaseStatusSourceAdmin",
+                "PurchaseStatusSourceConfirmation"
+            ]
+        },
         "models.Reader": {
             "type": "object",
             "properties": {
@@ -866,6 +3720,74 @@ const docTemplate = `{
                 "ReaderStatusUnknown"
             ]
         },
+        "models.Role": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Permission"
+                    }
+                }
+            }
+        },
+        "models.StockMovement": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "item_id": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "purchase_id": {
+                    "description": "only set for sales",
+                    "type": "string"
+                },
+                "reason": {
+                    "$ref": "#/definitions/models.StockMovementReason"
+                },
+                "stock_after": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.StockMovementReason": {
+            "type": "string",
+            "enum": [
+                "sale",
+                "restock",
+                "breakage",
+                "correction"
+            ],
+            "x-enum-varnames": [
+                "StockMovementReasonSale",
+                "StockMovementReasonRestock",
+                "StockMovementReasonBreakage",
+                "StockMovementReasonCorrection"
+            ]
+        },
         "models.TransactionFullStatus": {
             "type": "string",
             "enum": [
@@ -881,7 +3803,208 @@ const docTemplate = `{
                 "TransactionFullStatusSuccessful"
             ]
         },
-        "models.User": {
+        "models.UserToken": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "readers.CreateReaderBody": {
+            "type": "object",
+            "properties": {
+                "meta": {
+                    "description": "Set of user-defined key-value pairs attached to the object.\nMax properties: 50",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/readers.Meta"
+                        }
+                    ]
+                },
+                "name": {
+                    "description": "Custom human-readable, user-defined name for easier identification of the reader.\nMax length: 500",
+                    "type": "string"
+                },
+                "pairing_code": {
+                    "description": "The pairing code is a 8 or 9 character alphanumeric string that is displayed on a SumUp Device after initiating\nthe pairing. It is used to link the physical device to the created pairing.\nMin length: 8\nMax length: 9",
+                    "type": "string"
+                }
+            }
+        },
+        "readers.Meta": {
+            "type": "object",
+            "additionalProperties": {}
+        },
+        "v1.ChangePasswordInput": {
+            "type": "object",
+            "required": [
+                "new_password"
+            ],
+            "properties": {
+                "new_password": {
+                    "description": "bcrypt only uses the first 72 bytes",
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8
+                },
+                "old_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CreateCategoryInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "sort_index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.CreateDeviceInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "default_reader_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CreateItemInput": {
+            "type": "object",
+            "required": [
+                "name",
+                "price"
+            ],
+            "properties": {
+                "category_id": {
+                    "type": "string"
+                },
+                "image": {
+                    "type": "string"
+                },
+                "low_stock_threshold": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "sort_index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.CreatePurchaseInput": {
+            "type": "object",
+            "required": [
+                "payment_type"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "used only for topping up the balance, with card or cash",
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.PurchaseItemInput"
+                    }
+                },
+                "payment_type": {
+                    "$ref": "#/definitions/models.PaymentType"
+                },
+                "pin": {
+                    "description": "needed for balance payments above PIN_REQUIRED_ABOVE if the user has a pin",
+                    "type": "string"
+                },
+                "reader_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CreateStockMovementInput": {
+            "type": "object",
+            "required": [
+                "change",
+                "reason"
+            ],
+            "properties": {
+                "change": {
+                    "type": "integer"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "reason": {
+                    "enum": [
+                        "restock",
+                        "breakage",
+                        "correction"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StockMovementReason"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.CreateUserInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CreateUserTokenInput": {
+            "type": "object",
+            "required": [
+                "uid"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "uid": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CurrentUserResponse": {
             "type": "object",
             "properties": {
                 "balance": {
@@ -890,15 +4013,23 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "credit_limit": {
+                    "type": "integer"
+                },
                 "deleted_at": {
                     "$ref": "#/definitions/gorm.DeletedAt"
                 },
+                "effective_credit_limit": {
+                    "type": "integer"
+                },
+                "has_pin": {
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "string"
                 },
                 "image": {
-                    "type": "string",
-                    "default": "assets/empty.webp"
+                    "type": "string"
                 },
                 "is_active": {
                     "type": "boolean"
@@ -907,100 +4038,211 @@ const docTemplate = `{
                     "type": "boolean"
                 },
                 "is_restricted": {
-                    "description": "this entirely disables the balance element for the affected user",
                     "type": "boolean"
                 },
                 "is_trusted": {
                     "type": "boolean"
                 },
-                "name": {
+                "locked_until": {
                     "type": "string"
                 },
-                "password": {
+                "name": {
                     "type": "string"
                 },
+                "oidc_linked": {
+                    "type": "boolean"
+                },
+                "recent_purchases": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Purchase"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Role"
+                    }
+                },
                 "used_at": {
                     "type": "string"
                 }
             }
         },
-        "readers.CreateReaderBody": {
+        "v1.DeletePinInput": {
             "type": "object",
             "properties": {
-                "meta": {
-                    "description": "Set of user-defined key-value pairs attached to the object.\nMax properties: 50",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/readers.Meta"
-                        }
-                    ]
+                "password": {
+                    "description": "the current password of the user, not needed for users without a password",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.DeviceWithCredential": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
                 },
-                "name": {
-                    "description": "Custom human-readable, user-defined name for easier identification of the reader.\nMax length: 500",
+                "created_by": {
                     "type": "string"
                 },
-                "pairing_code": {
-                    "description": "The pairing code is a 8 or 9 character alphanumeric string that is displayed on a SumUp Device after initiating\nthe pairing. It is used to link the physical device to the created pairing.\nMin length: 8\nMax length: 9",
+                "credential": {
+                    "type": "string"
+                },
+                "default_reader_id": {
+                    "description": "used for card payments of the kiosk if no reader is given",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_seen_at": {
+                    "type": "string"
+                },
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "readers.Meta": {
+        "v1.ItemGroup": {
             "type": "object",
-            "additionalProperties": {}
+            "properties": {
+                "category": {
+                    "$ref": "#/definitions/models.Category"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Item"
+                    }
+                }
+            }
         },
-        "v1.CreateItemInput": {
+        "v1.PurchaseItemInput": {
             "type": "object",
             "required": [
-                "name",
-                "price"
+                "item_id",
+                "quantity"
             ],
             "properties": {
-                "image": {
-                    "type": "string"
-                },
-                "name": {
+                "item_id": {
                     "type": "string"
                 },
-                "price": {
-                    "type": "integer"
+                "quantity": {
+                    "description": "at most maxItemQuantity per item, checked by createPurchase",
+                    "type": "integer",
+                    "minimum": 1
                 }
             }
         },
-        "v1.CreatePurchaseInput": {
+        "v1.RoleInput": {
             "type": "object",
             "required": [
-                "payment_type"
+                "name"
             ],
             "properties": {
-                "amount": {
-                    "description": "used only for adding balance",
-                    "type": "integer"
+                "description": {
+                    "type": "string"
                 },
-                "items": {
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Item"
+                        "$ref": "#/definitions/models.Permission"
                     }
+                }
+            }
+        },
+        "v1.SalesReportLine": {
+            "type": "object",
+            "properties": {
+                "item_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
                 },
                 "payment_type": {
                     "$ref": "#/definitions/models.PaymentType"
                 },
-                "reader_id": {
+                "quantity": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.SetCreditLimitInput": {
+            "type": "object",
+            "properties": {
+                "credit_limit": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "v1.SetPinInput": {
+            "type": "object",
+            "required": [
+                "pin"
+            ],
+            "properties": {
+                "password": {
+                    "description": "the current password of the user, not needed for users without a password",
+                    "type": "string"
+                },
+                "pin": {
                     "type": "string"
                 }
             }
         },
-        "v1.CreateUserInput": {
+        "v1.SetUserRolesInput": {
+            "type": "object",
+            "properties": {
+                "role_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "v1.SortInput": {
             "type": "object",
             "required": [
-                "name"
+                "id"
             ],
             "properties": {
-                "name": {
+                "id": {
                     "type": "string"
                 },
-                "password": {
+                "sort_index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.TapPurchaseInput": {
+            "type": "object",
+            "required": [
+                "items",
+                "uid"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/v1.PurchaseItemInput"
+                    }
+                },
+                "pin": {
+                    "type": "string"
+                },
+                "uid": {
                     "type": "string"
                 }
             }
@@ -1016,6 +4258,25 @@ const docTemplate = `{
                 }
             }
         },
+        "v1.TransferBalanceInput": {
+            "type": "object",
+            "required": [
+                "amount",
+                "recipient_id"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "note": {
+                    "type": "string"
+                },
+                "recipient_id": {
+                    "type": "string"
+                }
+            }
+        },
         "v1.UnlinkReaderInput": {
             "type": "object",
             "properties": {
@@ -1026,6 +4287,126 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "v1.UpdateCategoryInput": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "sort_index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.UpdateCurrentUserImageInput": {
+            "type": "object",
+            "required": [
+                "image"
+            ],
+            "properties": {
+                "image": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.UpdateCurrentUserInput": {
+            "type": "object",
+            "properties": {
+                "image": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.UpdateDeviceInput": {
+            "type": "object",
+            "properties": {
+                "default_reader_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.UpdatePurchaseStatusInput": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "note": {
+                    "type": "string"
+                },
+                "status": {
+                    "enum": [
+                        "successful",
+                        "failed",
+                        "cancelled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.TransactionFullStatus"
+                        }
+                    ]
+                }
+            }
+        },
+        "v1.UpdateUserBalanceInput": {
+            "type": "object",
+            "required": [
+                "change",
+                "reason"
+            ],
+            "properties": {
+                "change": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.UpdateUserInput": {
+            "type": "object",
+            "properties": {
+                "image": {
+                    "type": "string"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "is_admin": {
+                    "type": "boolean"
+                },
+                "is_restricted": {
+                    "type": "boolean"
+                },
+                "is_trusted": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "oidc_subject": {
+                    "description": "links the user to an account at the identity provider, empty unlinks it",
+                    "type": "string"
+                }
+            }
+        },
+        "v1.VoidPurchaseInput": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -0,0 +1,35 @@
+package libs
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnvDuration parses the environment variable as a duration (e.g. "90s" or "5m"), falling back if it is unset or
+// invalid.
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvInt parses the environment variable as an integer, falling back if it is unset or invalid.
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvBool parses the environment variable as a boolean ("true", "1", ...), falling back if it is unset or invalid.
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
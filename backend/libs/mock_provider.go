@@ -0,0 +1,203 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	"github.com/google/uuid"
+)
+
+// MockProvider implements PaymentProvider without any external service. Reader checkouts are resolved after
+// MOCK_PAYMENT_DELAY (default 5s) with the status in MOCK_PAYMENT_OUTCOME (default successful), which is reported
+// like a SumUp webhook to MOCK_PAYMENT_CALLBACK_URL (default http://localhost:8080/payment/v1/callback).
+type MockProvider struct {
+	mutex        sync.Mutex
+	readers      map[string]*sumupmodels.Reader
+	transactions map[string]*ProviderTransaction
+	checkouts    map[string]string // reader id -> client transaction id of the running checkout
+}
+
+const mockMerchantCode = "MOCK"
+
+func NewMockProvider() *MockProvider {
+	provider := &MockProvider{
+		readers:      make(map[string]*sumupmodels.Reader),
+		transactions: make(map[string]*ProviderTransaction),
+		checkouts:    make(map[string]string),
+	}
+
+	now := time.Now()
+	reader := sumupmodels.Reader{ReaderId: "rdr_MOCK0000000000000000000000", Name: "Mock Reader", Status: sumupmodels.ReaderStatusPaired, Device: sumupmodels.ReaderDevice{Identifier: "mock", Model: sumupmodels.ReaderDeviceModelVirtualSolo}, CreatedAt: now, UpdatedAt: now}
+	provider.readers[string(reader.ReaderId)] = &reader
+	return provider
+}
+
+func (p *MockProvider) CreateReaderCheckout(readerId string, totalAmount uint, description *string) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.readers[readerId]; !ok {
+		return "", fmt.Errorf("error while creating reader checkout: reader %s not found", readerId)
+	}
+	if _, ok := p.checkouts[readerId]; ok {
+		return "", fmt.Errorf("error while creating reader checkout: reader %s is busy", readerId)
+	}
+
+	clientTransactionId := uuid.New().String()
+	p.transactions[clientTransactionId] = &ProviderTransaction{TransactionId: uuid.New().String(), ClientTransactionId: clientTransactionId, Status: sumupmodels.TransactionFullStatusPending, Amount: totalAmount, Currency: PaymentCurrency}
+	p.checkouts[readerId] = clientTransactionId
+	fmt.Printf("[INFO] Mock Payment: Started checkout %s of %d cents on reader %s\n", clientTransactionId, totalAmount, readerId)
+
+	delay, err := time.ParseDuration(os.Getenv("MOCK_PAYMENT_DELAY"))
+	if err != nil {
+		delay = 5 * time.Second
+	}
+	outcome := sumupmodels.TransactionFullStatus(os.Getenv("MOCK_PAYMENT_OUTCOME"))
+	if outcome == "" {
+		outcome = sumupmodels.TransactionFullStatusSuccessful
+	}
+	time.AfterFunc(delay, func() {
+		p.finishCheckout(readerId, outcome)
+	})
+
+	return clientTransactionId, nil
+}
+
+// finishCheckout sets the status of the checkout running on the reader and sends the webhook.
+func (p *MockProvider) finishCheckout(readerId string, status sumupmodels.TransactionFullStatus) {
+	p.mutex.Lock()
+	clientTransactionId, ok := p.checkouts[readerId]
+	if !ok {
+		p.mutex.Unlock()
+		return // already terminated
+	}
+	delete(p.checkouts, readerId)
+	transaction := p.transactions[clientTransactionId]
+	transaction.Status = status
+	payload := sumupmodels.ReaderCheckoutStatusChangePayload{ClientTransactionId: clientTransactionId, MerchantCode: mockMerchantCode, Status: status, TransactionId: transaction.TransactionId}
+	p.mutex.Unlock()
+
+	fmt.Printf("[INFO] Mock Payment: Checkout %s on reader %s is %s\n", clientTransactionId, readerId, status)
+	sendMockWebhook(payload)
+}
+
+func sendMockWebhook(payload sumupmodels.ReaderCheckoutStatusChangePayload) {
+	callbackUrl := os.Getenv("MOCK_PAYMENT_CALLBACK_URL")
+	if callbackUrl == "" {
+		callbackUrl = "http://localhost:8080/payment/v1/callback"
+	}
+
+	body, err := json.Marshal(sumupmodels.ReaderCheckoutStatusChange{Id: uuid.New().String(), EventType: "solo.transaction.updated", Payload: payload, UpdatedAt: time.Now()})
+	if err != nil {
+		fmt.Printf("[ERROR] Mock Payment: Error marshalling webhook: %s\n", err.Error())
+		return
+	}
+
+	response, err := webhookClient.Post(callbackUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		fmt.Printf("[ERROR] Mock Payment: Error sending webhook to %s: %s\n", callbackUrl, err.Error())
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		fmt.Printf("[ERROR] Mock Payment: Webhook to %s returned status %d\n", callbackUrl, response.StatusCode)
+	}
+}
+
+func (p *MockProvider) TerminateReaderCheckout(readerId string) error {
+	p.mutex.Lock()
+	_, ok := p.checkouts[readerId]
+	p.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no checkout running on reader %s", readerId)
+	}
+
+	go p.finishCheckout(readerId, sumupmodels.TransactionFullStatusCancelled)
+	return nil
+}
+
+func (p *MockProvider) ListReaders() ([]sumupmodels.Reader, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var result []sumupmodels.Reader
+	for _, v := range p.readers {
+		result = append(result, *v)
+	}
+	return result, nil
+}
+
+func (p *MockProvider) GetReader(readerId string) (*sumupmodels.Reader, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	reader, ok := p.readers[readerId]
+	if !ok {
+		return nil, fmt.Errorf("reader %s not found", readerId)
+	}
+	result := *reader
+	return &result, nil
+}
+
+// PairReader accepts any pairing code and pairs the reader immediately.
+func (p *MockProvider) PairReader(name *string, pairingCode string) (*sumupmodels.Reader, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	reader := sumupmodels.Reader{ReaderId: sumupmodels.ReaderId("rdr_" + strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")[:26])), Status: sumupmodels.ReaderStatusPaired, Device: sumupmodels.ReaderDevice{Identifier: pairingCode, Model: sumupmodels.ReaderDeviceModelVirtualSolo}, CreatedAt: now, UpdatedAt: now}
+	if name != nil {
+		reader.Name = sumupmodels.ReaderName(*name)
+	}
+	p.readers[string(reader.ReaderId)] = &reader
+
+	result := reader
+	return &result, nil
+}
+
+func (p *MockProvider) UnpairReader(readerId string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.readers[readerId]; !ok {
+		return fmt.Errorf("reader %s not found", readerId)
+	}
+	delete(p.readers, readerId)
+	return nil
+}
+
+func (p *MockProvider) Refund(transactionId string, clientTransactionId string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	transaction, ok := p.transactions[clientTransactionId]
+	if !ok || (transactionId != "" && transaction.TransactionId != transactionId) {
+		return fmt.Errorf("error while refunding transaction: transaction not found")
+	}
+	if transaction.Status != sumupmodels.TransactionFullStatusSuccessful {
+		return fmt.Errorf("error while refunding transaction: transaction is %s", transaction.Status)
+	}
+
+	fmt.Printf("[INFO] Mock Payment: Refunded transaction %s\n", transaction.TransactionId)
+	return nil
+}
+
+func (p *MockProvider) GetTransaction(clientTransactionId string) (*ProviderTransaction, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	transaction, ok := p.transactions[clientTransactionId]
+	if !ok {
+		return nil, fmt.Errorf("error while getting transaction: transaction not found")
+	}
+	result := *transaction
+	return &result, nil
+}
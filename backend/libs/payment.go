@@ -0,0 +1,142 @@
+package libs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"metalab/metadrinks/models"
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	"gorm.io/gorm"
+)
+
+// PaymentProvider is the backend the card readers and card transactions are managed with.
+type PaymentProvider interface {
+	// CreateReaderCheckout starts a checkout of totalAmount (in cents) on the reader and returns its client
+	// transaction id. The result of the checkout is reported to the callback endpoint.
+	CreateReaderCheckout(readerId string, totalAmount uint, description *string) (string, error)
+	// TerminateReaderCheckout stops the checkout that is currently running on the reader.
+	TerminateReaderCheckout(readerId string) error
+	ListReaders() ([]sumupmodels.Reader, error)
+	GetReader(readerId string) (*sumupmodels.Reader, error)
+	// PairReader links a new reader using the pairing code shown on the device.
+	PairReader(name *string, pairingCode string) (*sumupmodels.Reader, error)
+	UnpairReader(readerId string) error
+	// Refund refunds the full amount of a transaction. If transactionId is empty, the transaction is looked up by
+	// clientTransactionId.
+	Refund(transactionId string, clientTransactionId string) error
+	GetTransaction(clientTransactionId string) (*ProviderTransaction, error)
+}
+
+// ProviderTransaction is a card transaction as reported by the payment provider.
+type ProviderTransaction struct {
+	TransactionId       string
+	ClientTransactionId string
+	Status              sumupmodels.TransactionFullStatus
+	Amount              uint // in cents
+	Currency            string
+}
+
+// PaymentCurrency is the currency all card payments are made in.
+const PaymentCurrency = "EUR"
+
+// PaymentProviderName selects the payment provider.
+//
+// Possible values:
+//
+// - `sumup`: The SumUp API is used. Requires SUMUP_API_KEY.
+// - `mock`: A local simulated provider, which sends fake webhooks to the callback endpoint. No card is charged.
+type PaymentProviderName string
+
+const (
+	PaymentProviderSumup PaymentProviderName = "sumup"
+	PaymentProviderMock  PaymentProviderName = "mock"
+)
+
+var Provider PaymentProvider
+
+// GetPaymentProviderName returns the provider set in PAYMENT_PROVIDER, defaulting to sumup.
+func GetPaymentProviderName() PaymentProviderName {
+	if PaymentProviderName(os.Getenv("PAYMENT_PROVIDER")) == PaymentProviderMock {
+		return PaymentProviderMock
+	}
+	return PaymentProviderSumup
+}
+
+func InitPaymentProvider() {
+	switch GetPaymentProviderName() {
+	case PaymentProviderMock:
+		Provider = NewMockProvider()
+		fmt.Printf("[INFO] Payment: Using the mock payment provider, no real payments are made\n")
+	default:
+		Provider = NewSumupProvider(os.Getenv("SUMUP_API_KEY"))
+	}
+}
+
+// InitAPIReaders replaces the readers in the database with the readers known to the payment provider.
+func InitAPIReaders() {
+	apiReaders, err := Provider.ListReaders()
+	if err != nil {
+		fmt.Printf("[ERROR] Payment: Error fetching readers: %s\n", err.Error())
+		return
+	}
+
+	var r []sumupmodels.Reader
+	models.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&r)
+
+	readersCount := 0
+	for _, v := range apiReaders {
+		models.DB.Create(&v)
+		readersCount++
+	}
+	fmt.Printf("[INFO] Payment: Initialized %d reader(s).\n", readersCount)
+}
+
+func InitiallyCheckIfReaderIsReady(ReaderId string) (Result *sumupmodels.Reader, Error error) {
+	readerReady := false
+	count := 5
+	secondsBetween := 5
+	for i := 0; i <= count; i++ {
+		time.Sleep(time.Second * time.Duration(secondsBetween))
+		reader, err := Provider.GetReader(ReaderId)
+		if err != nil {
+			fmt.Printf("[ERROR] Payment: Error getting reader %s (Iteration %d/%d): %s\n", ReaderId, i, count, err.Error())
+			continue
+		}
+		if reader.Status != sumupmodels.ReaderStatusPaired {
+			editedReader := sumupmodels.Reader{Status: reader.Status}
+			models.DB.Where(&sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(ReaderId)}).Updates(editedReader)
+			fmt.Printf("[INFO] Payment: Reader %s not ready (Iteration %d/%d)\n", ReaderId, i, count)
+			continue
+		}
+		fmt.Printf("[INFO] Payment: Reader %s returned ready\n", ReaderId)
+		readerReady = true
+		break
+	}
+	if readerReady {
+		editedReader := sumupmodels.Reader{Status: sumupmodels.ReaderStatusPaired}
+		models.DB.Where(&sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(ReaderId)}).Updates(editedReader)
+		fmt.Printf("[INFO] Payment: Reader %s is ready\n", ReaderId)
+		return &editedReader, nil
+	}
+	fmt.Printf("[ERROR] Payment: Reader %s not ready after waiting %d seconds\n", ReaderId, count*secondsBetween)
+	return nil, fmt.Errorf("reader %s not ready after waiting %d seconds", ReaderId, count*secondsBetween)
+}
+
+//goland:noinspection GoUnusedExportedFunction
+func CheckIfReaderIsReady(ReaderId string) (IsReady bool, Error error) {
+	reader, err := Provider.GetReader(ReaderId)
+	if err != nil {
+		fmt.Printf("[ERROR] Payment: Error getting reader %s: %s\n", ReaderId, err.Error())
+		return false, err
+	}
+	if reader.Status != sumupmodels.ReaderStatusPaired {
+		fmt.Printf("[INFO] Payment: Reader %s not ready\n", ReaderId)
+		return false, fmt.Errorf("reader is not ready")
+	}
+	editedReader := sumupmodels.Reader{Status: sumupmodels.ReaderStatusPaired}
+	models.DB.Where(&sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(ReaderId)}).Updates(editedReader)
+	fmt.Printf("[INFO] Payment: Reader %s returned ready\n", ReaderId)
+	return true, nil
+}
@@ -3,111 +3,173 @@ package libs
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
-	"time"
+	"strings"
 
-	"metalab/metadrinks/models"
 	sumupmodels "metalab/metadrinks/models/sumup"
 
 	"github.com/sumup/sumup-go"
 	"github.com/sumup/sumup-go/client"
 	"github.com/sumup/sumup-go/merchant"
 	"github.com/sumup/sumup-go/readers"
-	"gorm.io/gorm"
+	"github.com/sumup/sumup-go/transactions"
 )
 
-var (
-	SumupAccount *merchant.MerchantAccount
-	SumupClient  *sumup.Client
-)
+// SumupProvider implements PaymentProvider with the SumUp API.
+type SumupProvider struct {
+	Account *merchant.MerchantAccount
+	Client  *sumup.Client
+}
 
-func Login(apiKey string) {
-	SumupClient = sumup.NewClient(client.WithAPIKey(apiKey))
+func NewSumupProvider(apiKey string) *SumupProvider {
+	provider := &SumupProvider{Client: sumup.NewClient(client.WithAPIKey(apiKey))}
 
-	account, err := SumupClient.Merchant.Get(context.Background(), merchant.GetAccountParams{})
+	account, err := provider.Client.Merchant.Get(context.Background(), merchant.GetAccountParams{})
 	if err != nil {
 		fmt.Printf("[ERROR] SumUp API: Error getting merchant account: %s\n", err.Error())
-		return
+		return provider
 	}
 
 	fmt.Printf("[INFO] SumUp API: Authorized for merchant %q (%s)\n\n", *account.MerchantProfile.MerchantCode, *account.MerchantProfile.CompanyName)
-	SumupAccount = account
+	provider.Account = account
+	return provider
+}
+
+func (p *SumupProvider) merchantCode() (string, error) {
+	if p.Account == nil {
+		return "", fmt.Errorf("not authorized with the SumUp API")
+	}
+	return *p.Account.MerchantProfile.MerchantCode, nil
+}
+
+func (p *SumupProvider) CreateReaderCheckout(readerId string, totalAmount uint, description *string) (string, error) {
+	merchantCode, err := p.merchantCode()
+	if err != nil {
+		return "", err
+	}
+
+	returnUrl := os.Getenv("SUMUP_RETURN_URL")
+	response, checkoutErr := p.Client.Readers.CreateCheckout(context.Background(), merchantCode, readerId, readers.CreateReaderCheckoutBody{Description: description, ReturnUrl: &returnUrl, TotalAmount: readers.CreateReaderCheckoutAmount{Currency: PaymentCurrency, MinorUnit: 2, Value: int(totalAmount)}})
+	if checkoutErr != nil {
+		return "", fmt.Errorf("error while creating reader checkout: %s", checkoutErr.Error())
+	}
+	return *response.Data.ClientTransactionId, nil
 }
 
-func InitAPIReaders() {
-	response, err := SumupClient.Readers.List(context.Background(), *SumupAccount.MerchantProfile.MerchantCode)
+func (p *SumupProvider) TerminateReaderCheckout(readerId string) error {
+	merchantCode, err := p.merchantCode()
 	if err != nil {
-		fmt.Printf("[ERROR] SumUp API: Error fetching readers: %s\n", err.Error())
-		return
+		return err
 	}
+	return p.Client.Readers.TerminateCheckout(context.Background(), merchantCode, readerId)
+}
 
-	var r []sumupmodels.Reader
-	models.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&r)
+func (p *SumupProvider) ListReaders() ([]sumupmodels.Reader, error) {
+	merchantCode, err := p.merchantCode()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.Client.Readers.List(context.Background(), merchantCode)
+	if err != nil {
+		return nil, err
+	}
 
-	// lookup if readers are in db by reader id, create only non-added ones.
-	readersCount := 0
+	var result []sumupmodels.Reader
 	for _, v := range response.Items {
-		apiReader := sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(v.Id), Name: sumupmodels.ReaderName(v.Name), Status: sumupmodels.ReaderStatus(v.Status), Device: sumupmodels.ReaderDevice{Identifier: v.Device.Identifier, Model: sumupmodels.ReaderDeviceModel(v.Device.Model)}, CreatedAt: v.CreatedAt, UpdatedAt: v.UpdatedAt}
-		models.DB.Create(&apiReader)
-		readersCount++
+		result = append(result, convertSumupReader(&v))
 	}
-	fmt.Printf("[INFO] SumUp API: Initialized %d reader(s).\n", readersCount)
+	return result, nil
 }
 
-func StartReaderCheckout(ReaderId string, TotalAmount uint, Description *string) (ClientTransactionId string, Error error) {
-	returnUrl := os.Getenv("SUMUP_RETURN_URL")
-	response, checkoutErr := SumupClient.Readers.CreateCheckout(context.Background(), *SumupAccount.MerchantProfile.MerchantCode, ReaderId, readers.CreateReaderCheckoutBody{Description: Description, ReturnUrl: &returnUrl, TotalAmount: readers.CreateReaderCheckoutAmount{Currency: "EUR", MinorUnit: 2, Value: int(TotalAmount)}})
-	if checkoutErr != nil {
-		return "error", fmt.Errorf("error while creating reader checkout: %s", checkoutErr.Error())
+func (p *SumupProvider) GetReader(readerId string) (*sumupmodels.Reader, error) {
+	merchantCode, err := p.merchantCode()
+	if err != nil {
+		return nil, err
 	}
-	return *response.Data.ClientTransactionId, nil
+
+	reader, err := p.Client.Readers.Get(context.Background(), merchantCode, readers.ReaderId(readerId), readers.GetReaderParams{})
+	if err != nil {
+		return nil, err
+	}
+	result := convertSumupReader(reader)
+	return &result, nil
+}
+
+func (p *SumupProvider) PairReader(name *string, pairingCode string) (*sumupmodels.Reader, error) {
+	merchantCode, err := p.merchantCode()
+	if err != nil {
+		return nil, err
+	}
+
+	body := readers.CreateReaderBody{PairingCode: readers.ReaderPairingCode(pairingCode)}
+	if name != nil {
+		readerName := readers.ReaderName(*name)
+		body.Name = &readerName
+	}
+	reader, err := p.Client.Readers.Create(context.Background(), merchantCode, body)
+	if err != nil {
+		return nil, err
+	}
+	result := convertSumupReader(reader)
+	return &result, nil
+}
+
+func (p *SumupProvider) UnpairReader(readerId string) error {
+	merchantCode, err := p.merchantCode()
+	if err != nil {
+		return err
+	}
+	return p.Client.Readers.DeleteReader(context.Background(), merchantCode, readers.ReaderId(readerId))
 }
 
-func InitiallyCheckIfReaderIsReady(ReaderId string) (Result *sumupmodels.Reader, Error error) {
-	readerReady := false
-	count := 5
-	secondsBetween := 5
-	for i := 0; i <= count; i++ {
-		time.Sleep(time.Second * time.Duration(secondsBetween))
-		// response, err := SumupClient.Readers.List(context.Background(), *SumupAccount.MerchantProfile.MerchantCode)
-		reader, err := SumupClient.Readers.Get(context.Background(), *SumupAccount.MerchantProfile.MerchantCode, readers.ReaderId(ReaderId), readers.GetReaderParams{})
+func (p *SumupProvider) Refund(transactionId string, clientTransactionId string) error {
+	if transactionId == "" {
+		transaction, err := p.GetTransaction(clientTransactionId)
 		if err != nil {
-			fmt.Printf("[ERROR] SumUp API: Error getting reader %s (Iteration %d/%d): %s\n", ReaderId, i, count, err.Error())
-			continue
+			return err
 		}
-		if reader.Status != readers.ReaderStatusPaired {
-			editedReader := sumupmodels.Reader{Status: sumupmodels.ReaderStatus(reader.Status)}
-			models.DB.Where(&sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(ReaderId)}).Updates(editedReader)
-			fmt.Printf("[INFO] SumUp API: Reader %s not ready (Iteration %d/%d)\n", ReaderId, i, count)
-			continue
+		if transaction.TransactionId == "" {
+			return fmt.Errorf("transaction for client transaction id %s has no id", clientTransactionId)
 		}
-		fmt.Printf("[INFO] SumUp API: Reader %s returned ready\n", ReaderId)
-		readerReady = true
-		break
-	}
-	if readerReady {
-		editedReader := sumupmodels.Reader{Status: sumupmodels.ReaderStatusPaired}
-		models.DB.Where(&sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(ReaderId)}).Updates(editedReader)
-		fmt.Printf("[INFO] SumUp API: Reader %s is ready\n", ReaderId)
-		return &editedReader, nil
-	}
-	fmt.Printf("[ERROR] SumUp API: Reader %s not ready after waiting %d seconds\n", ReaderId, count*secondsBetween)
-	return nil, fmt.Errorf("reader %s not ready after waiting %d seconds", ReaderId, count*secondsBetween)
+		transactionId = transaction.TransactionId
+	}
+
+	if err := p.Client.Transactions.Refund(context.Background(), transactionId, transactions.RefundTransactionBody{}); err != nil {
+		return fmt.Errorf("error while refunding transaction: %s", err.Error())
+	}
+	fmt.Printf("[INFO] SumUp API: Refunded transaction %s\n", transactionId)
+	return nil
 }
 
-//goland:noinspection GoUnusedExportedFunction
-func CheckIfReaderIsReady(ReaderId string) (IsReady bool, Error error) {
-	reader, err := SumupClient.Readers.Get(context.Background(), *SumupAccount.MerchantProfile.MerchantCode, readers.ReaderId(ReaderId), readers.GetReaderParams{})
+func (p *SumupProvider) GetTransaction(clientTransactionId string) (*ProviderTransaction, error) {
+	merchantCode, err := p.merchantCode()
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := p.Client.Transactions.Get(context.Background(), merchantCode, transactions.GetTransactionV21Params{ClientTransactionId: &clientTransactionId})
 	if err != nil {
-		fmt.Printf("[ERROR] SumUp API: Error getting reader %s: %s\n", ReaderId, err.Error())
-		return false, err
+		return nil, fmt.Errorf("error while getting transaction: %s", err.Error())
 	}
-	if reader.Status != readers.ReaderStatusPaired {
-		fmt.Printf("[INFO] SumUp API: Reader %s not ready\n", ReaderId)
-		return false, fmt.Errorf("reader is not ready")
+
+	result := ProviderTransaction{ClientTransactionId: clientTransactionId}
+	if transaction.Id != nil {
+		result.TransactionId = *transaction.Id
+	}
+	if transaction.Status != nil {
+		result.Status = sumupmodels.TransactionFullStatus(strings.ToLower(string(*transaction.Status))) // the api uses upper case, webhooks lower case
+	}
+	if transaction.Amount != nil {
+		result.Amount = uint(math.Round(*transaction.Amount * 100))
 	}
-	editedReader := sumupmodels.Reader{Status: sumupmodels.ReaderStatusPaired}
-	models.DB.Where(&sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(ReaderId)}).Updates(editedReader)
-	fmt.Printf("[INFO] SumUp API: Reader %s returned ready\n", ReaderId)
-	return true, nil
+	if transaction.Currency != nil {
+		result.Currency = string(*transaction.Currency)
+	}
+	return &result, nil
+}
+
+func convertSumupReader(reader *readers.Reader) sumupmodels.Reader {
+	return sumupmodels.Reader{ReaderId: sumupmodels.ReaderId(reader.Id), Name: sumupmodels.ReaderName(reader.Name), Status: sumupmodels.ReaderStatus(reader.Status), Device: sumupmodels.ReaderDevice{Identifier: reader.Device.Identifier, Model: sumupmodels.ReaderDeviceModel(reader.Device.Model)}, CreatedAt: reader.CreatedAt, UpdatedAt: reader.UpdatedAt}
 }
@@ -0,0 +1,54 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// OutboundWebhook is the body that is posted to every configured outbound webhook url.
+type OutboundWebhook struct {
+	Event     string    `json:"event"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SendOutboundWebhooks posts the event to all urls in the comma-separated environment variable urlsVar.
+// The requests are sent in the background, failures are only logged.
+func SendOutboundWebhooks(urlsVar string, event string, data any) {
+	var urls []string
+	for _, v := range strings.Split(os.Getenv(urlsVar), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			urls = append(urls, v)
+		}
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(OutboundWebhook{Event: event, Data: data, CreatedAt: time.Now()})
+	if err != nil {
+		fmt.Printf("[ERROR] Webhooks: Error marshalling %s event: %s\n", event, err.Error())
+		return
+	}
+
+	for _, url := range urls {
+		go func(url string) {
+			response, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				fmt.Printf("[ERROR] Webhooks: Error sending %s event to %s: %s\n", event, url, err.Error())
+				return
+			}
+			defer response.Body.Close()
+			if response.StatusCode >= 300 {
+				fmt.Printf("[ERROR] Webhooks: %s returned status %d for %s event\n", url, response.StatusCode, event)
+			}
+		}(url)
+	}
+}
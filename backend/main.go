@@ -12,6 +12,7 @@ import (
 	"metalab/metadrinks/controllers/api"
 	"metalab/metadrinks/controllers/auth"
 	"metalab/metadrinks/controllers/payment"
+	paymentv1 "metalab/metadrinks/controllers/payment/v1"
 	"metalab/metadrinks/libs"
 	"metalab/metadrinks/models"
 
@@ -38,8 +39,6 @@ func main() {
 	}
 
 	enforcedVars := []string{
-		"SUMUP_API_KEY",
-		"SUMUP_RETURN_URL",
 		"JWT_SECRET",
 		"GIN_TRUSTED_PROXIES",
 		"DB_HOST",
@@ -49,6 +48,9 @@ func main() {
 		"DB_PORT",
 		"DB_TIMEZONE",
 	}
+	if libs.GetPaymentProviderName() == libs.PaymentProviderSumup {
+		enforcedVars = append(enforcedVars, "SUMUP_API_KEY", "SUMUP_RETURN_URL")
+	}
 	for _, v := range enforcedVars {
 		if os.Getenv(v) == "" {
 			panic("Environment variable " + v + " is not set. Please set it before running the application.")
@@ -59,7 +61,7 @@ func main() {
 
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
-	corsConfig.AddAllowHeaders("Authorization")
+	corsConfig.AddAllowHeaders("Authorization", auth.DeviceHeaderName)
 	r.Use(cors.New(corsConfig))
 
 	trustedProxies := strings.Split(os.Getenv("GIN_TRUSTED_PROXIES"), ",")
@@ -67,8 +69,9 @@ func main() {
 
 	models.ConnectDatabase()
 
-	libs.Login(os.Getenv("SUMUP_API_KEY"))
+	libs.InitPaymentProvider()
 	libs.InitAPIReaders()
+	paymentv1.StartReconciliation()
 
 	authMiddleware, err := jwt.New(auth.InitParams())
 	if err != nil {
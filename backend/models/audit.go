@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent records a security relevant event, like a failed login.
+type AuditEvent struct {
+	AuditEventId uuid.UUID      `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	Type         AuditEventType `json:"type" gorm:"index"`
+	UserId       *uuid.UUID     `json:"user_id,omitempty" gorm:"type:uuid;index"` // null if the username does not exist
+	Username     string         `json:"username,omitempty"`
+	IpAddress    string         `json:"ip_address"`
+	Detail       string         `json:"detail,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	CreatedBy    *uuid.UUID     `json:"created_by,omitempty" gorm:"type:uuid"` // the admin for events triggered by admins
+}
+
+// AuditEventType gives information about what happened.
+//
+// Possible values:
+//
+// - `login_failed`: A login failed because of wrong credentials.
+// - `login_throttled`: A login was refused because of too many failed attempts from the username or ip address.
+// - `account_locked`: An account was locked after too many failed logins.
+// - `account_unlocked`: An admin unlocked an account.
+type AuditEventType string
+
+const (
+	AuditEventLoginFailed     AuditEventType = "login_failed"
+	AuditEventLoginThrottled  AuditEventType = "login_throttled"
+	AuditEventAccountLocked   AuditEventType = "account_locked"
+	AuditEventAccountUnlocked AuditEventType = "account_unlocked"
+)
+
+// RecordAuditEvent stores the event. Errors are only logged, so a failing audit trail does not block logins.
+func RecordAuditEvent(event *AuditEvent) {
+	if err := DB.Create(event).Error; err != nil {
+		fmt.Printf("error while recording audit event: %s\n", err.Error())
+	}
+}
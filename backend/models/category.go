@@ -0,0 +1,10 @@
+package models
+
+import "github.com/google/uuid"
+
+// Category groups items on the kiosk, e.g. soft drinks, beer, snacks or merch.
+type Category struct {
+	CategoryId uuid.UUID `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()" example:"00000000-0000-0000-0000-000000000000"`
+	Name       string    `json:"name" gorm:"unique"`
+	SortIndex  int       `json:"sort_index" gorm:"default:0"`
+}
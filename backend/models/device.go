@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Device is a registered kiosk. Kiosks authenticate with a long-lived credential, which is only stored hashed.
+type Device struct {
+	DeviceId        uuid.UUID  `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	Name            string     `json:"name" gorm:"unique"`
+	CredentialHash  string     `json:"-" gorm:"uniqueIndex"`
+	DefaultReaderId string     `json:"default_reader_id,omitempty"` // used for card payments of the kiosk if no reader is given
+	CreatedAt       time.Time  `json:"created_at"`
+	CreatedBy       uuid.UUID  `json:"created_by"`
+	LastSeenAt      *time.Time `json:"last_seen_at"`
+}
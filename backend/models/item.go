@@ -3,8 +3,12 @@ package models
 import "github.com/google/uuid"
 
 type Item struct {
-	ItemId uuid.UUID `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()" example:"00000000-0000-0000-0000-000000000000"`
-	Name   string    `json:"name" gorm:"unique"`
-	Image  string    `json:"image" default:"assets/empty.webp"`
-	Price  uint      `json:"price"`
+	ItemId            uuid.UUID  `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()" example:"00000000-0000-0000-0000-000000000000"`
+	Name              string     `json:"name" gorm:"unique"`
+	Image             string     `json:"image" default:"assets/empty.webp"`
+	Price             uint       `json:"price"`
+	CategoryId        *uuid.UUID `json:"category_id,omitempty" gorm:"type:uuid;index"`
+	SortIndex         int        `json:"sort_index" gorm:"default:0"`
+	Stock             int        `json:"stock" gorm:"default:0"`               // only changed through stock movements, may become negative
+	LowStockThreshold int        `json:"low_stock_threshold" gorm:"default:0"` // a stock alert is sent once the stock drops below this, 0 disables alerts
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BalanceTransaction is an entry of the append-only balance ledger. Every change of User.Balance is recorded as one,
+// so the balance of a user always equals the sum of their ledger entries.
+type BalanceTransaction struct {
+	BalanceTransactionId uuid.UUID              `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	UserId               uuid.UUID              `json:"user_id" gorm:"type:uuid;index"`
+	Type                 BalanceTransactionType `json:"type"`
+	Amount               int                    `json:"amount"` // positive amounts add balance, negative amounts remove it
+	BalanceAfter         int                    `json:"balance_after"`
+	PurchaseId           *uuid.UUID             `json:"purchase_id,omitempty" gorm:"type:uuid;index"`
+	CounterpartyId       *uuid.UUID             `json:"counterparty_id,omitempty" gorm:"type:uuid"` // the other user of a transfer
+	Note                 string                 `json:"note,omitempty"`
+	CreatedAt            time.Time              `json:"created_at"`
+	CreatedBy            uuid.UUID              `json:"created_by"`
+}
+
+// BalanceTransactionType gives information about why the balance of a user changed.
+//
+// Possible values:
+//
+// - `purchase`: The balance was used to pay for a purchase.
+// - `top_up`: Balance was added by the user.
+// - `refund`: A purchase paid with balance was refunded.
+// - `transfer`: Balance was transferred from or to another user.
+// - `correction`: The balance was corrected by an admin.
+type BalanceTransactionType string
+
+const (
+	BalanceTransactionTypePurchase   BalanceTransactionType = "purchase"
+	BalanceTransactionTypeTopUp      BalanceTransactionType = "top_up"
+	BalanceTransactionTypeRefund     BalanceTransactionType = "refund"
+	BalanceTransactionTypeTransfer   BalanceTransactionType = "transfer"
+	BalanceTransactionTypeCorrection BalanceTransactionType = "correction"
+)
+
+// ChangeBalance atomically adds entry.Amount to the balance of entry.UserId and appends entry to the ledger.
+// It should be called inside a transaction, so the ledger entry is rolled back together with the change. Soft-deleted
+// users are included, so refunds, voids and corrections still reach them.
+func ChangeBalance(tx *gorm.DB, entry *BalanceTransaction) error {
+	var user User
+
+	result := tx.Unscoped().Model(&user).Clauses(clause.Returning{Columns: []clause.Column{{Name: "balance"}}}).Where("user_id = ?", entry.UserId).Update("balance", gorm.Expr("balance + ?", entry.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	entry.BalanceAfter = user.Balance
+	return tx.Create(entry).Error
+}
+
+// backfillLedger records the current balance of users without any ledger entries as an opening correction, so
+// balances from before the ledger existed are accounted for.
+func backfillLedger(db *gorm.DB) {
+	var users []User
+	db.Where("balance <> 0 AND NOT EXISTS (SELECT 1 FROM balance_transactions WHERE balance_transactions.user_id = users.user_id)").Find(&users)
+
+	for _, v := range users {
+		db.Create(&BalanceTransaction{UserId: v.UserID, Type: BalanceTransactionTypeCorrection, Amount: v.Balance, BalanceAfter: v.Balance, Note: "opening balance", CreatedBy: uuid.Nil})
+	}
+}
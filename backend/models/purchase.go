@@ -10,16 +10,52 @@ import (
 
 type Purchase struct {
 	PurchaseId          uuid.UUID                         `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
-	Items               []Item                            `json:"items,omitempty" gorm:"foreignKey:ItemID;type:bytes;serializer:gob"`
+	Items               []PurchaseItem                    `json:"items,omitempty" gorm:"foreignKey:PurchaseId;references:PurchaseId;constraint:OnDelete:CASCADE"`
 	PaymentType         PaymentType                       `json:"payment_type"`
 	TransactionStatus   sumupmodels.TransactionFullStatus `json:"status"`
 	ClientTransactionId string                            `json:"client_transaction_id,omitempty"`
+	TransactionId       string                            `json:"transaction_id,omitempty"` // sumup transaction id, needed for refunds
 	FinalCost           uint                              `json:"final_cost"`
-	RefundAmount        uint                              `json:"refund_amount,omitempty"` // adds balance to the user account
+	TopUpAmount         uint                              `json:"top_up_amount,omitempty" gorm:"column:refund_amount"` // added to the balance of the user once the purchase is successful
 	CreatedAt           time.Time                         `json:"created_at"`
 	CreatedBy           uuid.UUID                         `json:"created_by"` // uuid of user, otherwise null uuid (for guests)
+	VoidedAt            *time.Time                        `json:"voided_at,omitempty"`
+	VoidedBy            *uuid.UUID                        `json:"voided_by,omitempty" gorm:"type:uuid"`
+	VoidReason          string                            `json:"void_reason,omitempty"`
+	DrawerReconciledAt  *time.Time                        `json:"drawer_reconciled_at,omitempty"`             // set once the cash of a voided cash purchase was taken out of the drawer
+	CounterpartyId      *uuid.UUID                        `json:"counterparty_id,omitempty" gorm:"type:uuid"` // the other user of a transfer
+	StatusHistory       []PurchaseStatusChange            `json:"status_history,omitempty" gorm:"foreignKey:PurchaseId;references:PurchaseId;constraint:OnDelete:CASCADE"`
 }
 
+// PurchaseItem is a single line of a purchase. Name and UnitPrice are copied from the item when the purchase is
+// created, so later price changes do not alter past purchases.
+type PurchaseItem struct {
+	PurchaseItemId uuid.UUID `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	PurchaseId     uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	ItemId         uuid.UUID `json:"item_id" gorm:"type:uuid"`
+	Name           string    `json:"name"`
+	Quantity       uint      `json:"quantity"`
+	UnitPrice      uint      `json:"unit_price"`
+	LineTotal      uint      `json:"line_total"`
+}
+
+// CalculateFinalCost sums up the line totals of all purchase items.
+func (p *Purchase) CalculateFinalCost() uint {
+	var finalCost uint = 0
+	for _, v := range p.Items {
+		finalCost += v.LineTotal
+	}
+	return finalCost
+}
+
+// Statuses of purchases that were undone after they were successful. They are not used by SumUp. RefundPending is set
+// while the refund of a card payment is sent to the payment provider.
+const (
+	TransactionStatusVoided        sumupmodels.TransactionFullStatus = "voided"
+	TransactionStatusRefunded      sumupmodels.TransactionFullStatus = "refunded"
+	TransactionStatusRefundPending sumupmodels.TransactionFullStatus = "refund_pending"
+)
+
 // PaymentType The type of the payment object gives information about the type of payment.
 //
 // Possible values:
@@ -27,10 +63,12 @@ type Purchase struct {
 // - `cash`: The payment was made with cash.
 // - `unpaid`: The payment was made with a credit/debit card.
 // - `balance`: The payment was made using the balance of the logged-in user.
+// - `transfer`: Balance was transferred to another user (FinalCost) or received from another user (TopUpAmount).
 type PaymentType string
 
 const (
-	PaymentTypeCash    PaymentType = "cash"
-	PaymentTypeCard    PaymentType = "card"
-	PaymentTypeBalance PaymentType = "balance"
+	PaymentTypeCash     PaymentType = "cash"
+	PaymentTypeCard     PaymentType = "card"
+	PaymentTypeBalance  PaymentType = "balance"
+	PaymentTypeTransfer PaymentType = "transfer"
 )
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTransition = errors.New("invalid transaction status transition")
+
+// purchaseTransitions lists the statuses a purchase can be moved to from each status. Statuses which are missing are
+// final.
+var purchaseTransitions = map[sumupmodels.TransactionFullStatus][]sumupmodels.TransactionFullStatus{
+	sumupmodels.TransactionFullStatusPending:    {sumupmodels.TransactionFullStatusSuccessful, sumupmodels.TransactionFullStatusFailed, sumupmodels.TransactionFullStatusCancelled},
+	sumupmodels.TransactionFullStatusSuccessful: {TransactionStatusRefunded, TransactionStatusVoided, TransactionStatusRefundPending},
+	TransactionStatusRefundPending:              {TransactionStatusRefunded, sumupmodels.TransactionFullStatusSuccessful}, // back to successful if the refund failed
+}
+
+type purchaseTransition struct {
+	from sumupmodels.TransactionFullStatus
+	to   sumupmodels.TransactionFullStatus
+}
+
+// restrictedTransitions lists transitions only one source may make. A refund of a card purchase is started and
+// resolved only by the void, so e.g. a webhook cannot make a purchase successful again while it is being refunded.
+var restrictedTransitions = map[purchaseTransition]PurchaseStatusSource{
+	{sumupmodels.TransactionFullStatusSuccessful, TransactionStatusRefundPending}: PurchaseStatusSourceVoid,
+	{TransactionStatusRefundPending, TransactionStatusRefunded}:                   PurchaseStatusSourceVoid,
+	{TransactionStatusRefundPending, sumupmodels.TransactionFullStatusSuccessful}: PurchaseStatusSourceVoid,
+}
+
+// CanTransition reports whether source can move a purchase with the status from to the status to.
+func CanTransition(from sumupmodels.TransactionFullStatus, to sumupmodels.TransactionFullStatus, source PurchaseStatusSource) bool {
+	if !slices.Contains(purchaseTransitions[from], to) {
+		return false
+	}
+	if allowed, ok := restrictedTransitions[purchaseTransition{from, to}]; ok {
+		return source == allowed
+	}
+	return true
+}
+
+// PurchaseStatusChange is an entry of the status history of a purchase. The first entry of every purchase has an
+// empty From status.
+type PurchaseStatusChange struct {
+	PurchaseStatusChangeId uuid.UUID                         `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	PurchaseId             uuid.UUID                         `json:"-" gorm:"type:uuid;index"`
+	From                   sumupmodels.TransactionFullStatus `json:"from,omitempty"`
+	To                     sumupmodels.TransactionFullStatus `json:"to"`
+	Source                 PurchaseStatusSource              `json:"source"`
+	Note                   string                            `json:"note,omitempty"`
+	CreatedAt              time.Time                         `json:"created_at"`
+	CreatedBy              uuid.UUID                         `json:"created_by"` // null uuid for changes made by the payment provider
+}
+
+// PurchaseStatusSource gives information about what changed the status of a purchase.
+//
+// Possible values:
+//
+// - `checkout`: The purchase was created.
+// - `webhook`: The payment provider reported a new status.
+// - `reconciliation`: The background reconciliation fetched a new status from the payment provider or expired the purchase.
+// - `void`: The purchase was voided.
+// - `admin`: The status was set by an admin.
+// - `confirmation`: A cash top-up was confirmed by an admin or trusted user.
+type PurchaseStatusSource string
+
+const (
+	PurchaseStatusSourceCheckout       PurchaseStatusSource = "checkout"
+	PurchaseStatusSourceWebhook        PurchaseStatusSource = "webhook"
+	PurchaseStatusSourceReconciliation PurchaseStatusSource = "reconciliation"
+	PurchaseStatusSourceVoid           PurchaseStatusSource = "void"
+	PurchaseStatusSourceAdmin          PurchaseStatusSource = "admin"
+	PurchaseStatusSourceConfirmation   PurchaseStatusSource = "confirmation"
+)
+
+// AfterCreate records the initial status of a purchase in its status history.
+func (p *Purchase) AfterCreate(tx *gorm.DB) error {
+	return tx.Create(&PurchaseStatusChange{PurchaseId: p.PurchaseId, To: p.TransactionStatus, Source: PurchaseStatusSourceCheckout, CreatedBy: p.CreatedBy}).Error
+}
+
+// TransitionPurchase moves the purchase to a new status and records the change in its status history. Transitions
+// which are not allowed by the state machine or not for the source return ErrInvalidTransition. Top-ups credit the balance of the user once
+// they become successful, purchases which end up unpaid put their items back into stock. The purchase row should be
+// locked by tx.
+func TransitionPurchase(tx *gorm.DB, purchase *Purchase, to sumupmodels.TransactionFullStatus, source PurchaseStatusSource, changedBy uuid.UUID, note string) error {
+	from := purchase.TransactionStatus
+	if !CanTransition(from, to, source) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	switch to {
+	case sumupmodels.TransactionFullStatusSuccessful:
+		if purchase.TopUpAmount != 0 {
+			if err := ChangeBalance(tx, &BalanceTransaction{UserId: purchase.CreatedBy, Type: BalanceTransactionTypeTopUp, Amount: int(purchase.TopUpAmount), PurchaseId: &purchase.PurchaseId, Note: note, CreatedBy: changedBy}); err != nil {
+				return err
+			}
+		}
+	case sumupmodels.TransactionFullStatusFailed, sumupmodels.TransactionFullStatusCancelled, TransactionStatusVoided, TransactionStatusRefunded:
+		stockNote := "purchase " + string(to)
+		if note != "" {
+			stockNote += ": " + note
+		}
+		if err := RestorePurchaseStock(tx, purchase, stockNote); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(purchase).Update("transaction_status", to).Error; err != nil {
+		return err
+	}
+	purchase.TransactionStatus = to
+	return tx.Create(&PurchaseStatusChange{PurchaseId: purchase.PurchaseId, From: from, To: to, Source: source, Note: note, CreatedBy: changedBy}).Error
+}
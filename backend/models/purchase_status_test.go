@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	sumupmodels "metalab/metadrinks/models/sumup"
+
+	"github.com/google/uuid"
+)
+
+var allStatuses = []sumupmodels.TransactionFullStatus{
+	sumupmodels.TransactionFullStatusPending,
+	sumupmodels.TransactionFullStatusSuccessful,
+	sumupmodels.TransactionFullStatusFailed,
+	sumupmodels.TransactionFullStatusCancelled,
+	TransactionStatusVoided,
+	TransactionStatusRefunded,
+	TransactionStatusRefundPending,
+}
+
+var allSources = []PurchaseStatusSource{
+	PurchaseStatusSourceCheckout,
+	PurchaseStatusSourceWebhook,
+	PurchaseStatusSourceReconciliation,
+	PurchaseStatusSourceVoid,
+	PurchaseStatusSourceAdmin,
+	PurchaseStatusSourceConfirmation,
+}
+
+func TestCanTransition(t *testing.T) {
+	// allowed lists every allowed transition with the sources that may make it, nil means every source
+	allowed := map[purchaseTransition][]PurchaseStatusSource{
+		{sumupmodels.TransactionFullStatusPending, sumupmodels.TransactionFullStatusSuccessful}: nil,
+		{sumupmodels.TransactionFullStatusPending, sumupmodels.TransactionFullStatusFailed}:     nil,
+		{sumupmodels.TransactionFullStatusPending, sumupmodels.TransactionFullStatusCancelled}:  nil,
+		{sumupmodels.TransactionFullStatusSuccessful, TransactionStatusVoided}:                  nil,
+		{sumupmodels.TransactionFullStatusSuccessful, TransactionStatusRefunded}:                nil,
+		{sumupmodels.TransactionFullStatusSuccessful, TransactionStatusRefundPending}:           {PurchaseStatusSourceVoid},
+		{TransactionStatusRefundPending, TransactionStatusRefunded}:                             {PurchaseStatusSourceVoid},
+		{TransactionStatusRefundPending, sumupmodels.TransactionFullStatusSuccessful}:           {PurchaseStatusSourceVoid},
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			for _, source := range allSources {
+				sources, ok := allowed[purchaseTransition{from, to}]
+				want := ok && (sources == nil || slices.Contains(sources, source))
+
+				if got := CanTransition(from, to, source); got != want {
+					t.Errorf("CanTransition(%s, %s, %s) = %v, want %v", from, to, source, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestTransitionPurchaseRejectsInvalidTransitions(t *testing.T) {
+	// rejected transitions return before the database is used, so no transaction is needed
+	purchase := Purchase{TransactionStatus: TransactionStatusRefundPending}
+
+	err := TransitionPurchase(nil, &purchase, sumupmodels.TransactionFullStatusSuccessful, PurchaseStatusSourceWebhook, uuid.Nil, "")
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition, got %v", err)
+	}
+	if purchase.TransactionStatus != TransactionStatusRefundPending {
+		t.Errorf("status changed to %s", purchase.TransactionStatus)
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission is the right to use a group of endpoints. Admins have every permission.
+type Permission string
+
+const (
+	PermissionItemsManage     Permission = "items.manage"     // create, edit and sort items and categories
+	PermissionStockManage     Permission = "stock.manage"     // restock items and see their stock movements
+	PermissionUsersManage     Permission = "users.manage"     // edit, delete and restore users and see their details
+	PermissionBalancesManage  Permission = "balances.manage"  // correct balances, set credit limits, confirm top-ups and see ledgers
+	PermissionPurchasesManage Permission = "purchases.manage" // void any purchase, set purchase statuses and reconcile the cash drawer
+	PermissionReportsExport   Permission = "reports.export"   // export sales reports
+	PermissionReadersManage   Permission = "readers.manage"   // link and unlink card readers
+	PermissionDevicesManage   Permission = "devices.manage"   // register kiosks
+	PermissionRolesManage     Permission = "roles.manage"     // edit roles and assign them to users
+)
+
+var Permissions = []Permission{
+	PermissionItemsManage,
+	PermissionStockManage,
+	PermissionUsersManage,
+	PermissionBalancesManage,
+	PermissionPurchasesManage,
+	PermissionReportsExport,
+	PermissionReadersManage,
+	PermissionDevicesManage,
+	PermissionRolesManage,
+}
+
+// Role is a named set of permissions which can be assigned to users.
+type Role struct {
+	RoleId      uuid.UUID    `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	Name        string       `json:"name" gorm:"unique"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions" gorm:"serializer:json"`
+}
+
+// HasPermission reports whether the user is an admin or has a role with the permission. The roles of the user have to
+// be preloaded.
+func (u *User) HasPermission(permission Permission) bool {
+	if u.IsAdmin {
+		return true
+	}
+	for _, v := range u.Roles {
+		if slices.Contains(v.Permissions, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// seedRoles creates the default roles if they do not exist yet. Existing roles are left alone, so they can be edited.
+func seedRoles(db *gorm.DB) {
+	defaultRoles := []Role{
+		{Name: "admin", Description: "manages users, devices and roles", Permissions: Permissions},
+		{Name: "treasurer", Description: "corrects balances, handles the cash drawer and exports reports", Permissions: []Permission{PermissionBalancesManage, PermissionPurchasesManage, PermissionReportsExport}},
+		{Name: "stocker", Description: "restocks and edits items", Permissions: []Permission{PermissionItemsManage, PermissionStockManage}},
+	}
+
+	for _, v := range defaultRoles {
+		db.Where(Role{Name: v.Name}).FirstOrCreate(&v)
+	}
+}
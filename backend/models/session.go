@@ -0,0 +1,36 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of a user on a device. Access tokens carry the id of their session and are only accepted while
+// the session is active. The refresh token of a session is rotated on every refresh and only stored hashed.
+type Session struct {
+	SessionId        uuid.UUID  `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	UserId           uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	RefreshTokenHash string     `json:"-" gorm:"uniqueIndex"`
+	PreviousHash     string     `json:"-" gorm:"index"` // hash of the refresh token before the last rotation, to detect its reuse
+	UserAgent        string     `json:"user_agent"`
+	IpAddress        string     `json:"ip_address"`
+	CreatedAt        time.Time  `json:"created_at"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HashSecret returns the hash random secrets like refresh tokens and device credentials are stored as. They are long
+// and random, so a plain hash is enough.
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// IsActive reports whether the session was neither revoked nor has expired.
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
@@ -22,9 +22,19 @@ func ConnectDatabase() {
 		panic("Failed to connect to database!" + err.Error())
 	}
 
+	database.AutoMigrate(&Role{})
 	database.AutoMigrate(&User{})
+	database.AutoMigrate(&UserToken{})
+	database.AutoMigrate(&Session{})
+	database.AutoMigrate(&Device{})
+	database.AutoMigrate(&AuditEvent{})
+	database.AutoMigrate(&Category{})
 	database.AutoMigrate(&Item{})
 	database.AutoMigrate(&Purchase{})
+	database.AutoMigrate(&PurchaseItem{})
+	database.AutoMigrate(&PurchaseStatusChange{})
+	database.AutoMigrate(&StockMovement{})
+	database.AutoMigrate(&BalanceTransaction{})
 	database.AutoMigrate(&models.Reader{})
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(""), bcrypt.DefaultCost)
@@ -37,5 +47,8 @@ func ConnectDatabase() {
 		database.Create(&User{UserID: uuid.Nil, Name: "Guest", Password: string(hashedPassword), IsTrusted: false, UsedAt: time.Now().Local()})
 	}
 
+	seedRoles(database)
+	backfillLedger(database)
+
 	DB = database
 }
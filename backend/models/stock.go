@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrOutOfStock = errors.New("item is out of stock")
+
+// StockMovement records a single change of the stock level of an item.
+type StockMovement struct {
+	StockMovementId uuid.UUID           `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	ItemId          uuid.UUID           `json:"item_id" gorm:"type:uuid;index"`
+	Change          int                 `json:"change"`
+	StockAfter      int                 `json:"stock_after"`
+	Reason          StockMovementReason `json:"reason"`
+	PurchaseId      *uuid.UUID          `json:"purchase_id,omitempty" gorm:"type:uuid"` // only set for sales
+	Note            string              `json:"note,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	CreatedBy       uuid.UUID           `json:"created_by"`
+}
+
+// StockMovementReason gives information about why the stock level of an item changed.
+//
+// Possible values:
+//
+// - `sale`: The item was sold with a purchase.
+// - `restock`: New units of the item were added.
+// - `breakage`: Units of the item were broken, lost or expired.
+// - `correction`: The stock level was corrected after counting, or a sale was undone.
+type StockMovementReason string
+
+const (
+	StockMovementReasonSale       StockMovementReason = "sale"
+	StockMovementReasonRestock    StockMovementReason = "restock"
+	StockMovementReasonBreakage   StockMovementReason = "breakage"
+	StockMovementReasonCorrection StockMovementReason = "correction"
+)
+
+// AdjustStock atomically changes the stock level of movement.ItemId by movement.Change and records the movement.
+// If allowNegative is false and the stock would drop below zero, ErrOutOfStock is returned and nothing is changed.
+// It should be called inside a transaction, so the movement is rolled back together with the change.
+func AdjustStock(tx *gorm.DB, movement *StockMovement, allowNegative bool) error {
+	var item Item
+
+	query := tx.Model(&item).Clauses(clause.Returning{Columns: []clause.Column{{Name: "stock"}}}).Where("item_id = ?", movement.ItemId)
+	if !allowNegative && movement.Change < 0 {
+		query = query.Where("stock >= ?", -movement.Change)
+	}
+
+	result := query.Update("stock", gorm.Expr("stock + ?", movement.Change))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if !allowNegative && movement.Change < 0 {
+			return ErrOutOfStock
+		}
+		return gorm.ErrRecordNotFound
+	}
+
+	movement.StockAfter = item.Stock
+	return tx.Create(movement).Error
+}
+
+// RestorePurchaseStock puts the items of a purchase that did not go through back into stock.
+func RestorePurchaseStock(tx *gorm.DB, purchase *Purchase, note string) error {
+	for _, v := range purchase.Items {
+		movement := StockMovement{ItemId: v.ItemId, Change: int(v.Quantity), Reason: StockMovementReasonCorrection, PurchaseId: &purchase.PurchaseId, Note: note, CreatedBy: purchase.CreatedBy}
+		if err := AdjustStock(tx, &movement, true); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) { // deleted items are skipped
+			return err
+		}
+	}
+	return nil
+}
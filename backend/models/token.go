@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserToken is an NFC/RFID tag of a user. Only the keyed hash of the tag uid is stored, so the uids cannot be read
+// from the database.
+type UserToken struct {
+	UserTokenId uuid.UUID  `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
+	UserId      uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	UidHash     string     `json:"-" gorm:"uniqueIndex"`
+	Name        string     `json:"name"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+}
+
+// HashTokenUid normalizes the uid (case, separators) and hashes it with HMAC-SHA256 using TOKEN_HASH_KEY, falling back
+// to JWT_SECRET.
+func HashTokenUid(uid string) string {
+	key := os.Getenv("TOKEN_HASH_KEY")
+	if key == "" {
+		key = os.Getenv("JWT_SECRET")
+	}
+
+	normalizedUid := strings.ToUpper(strings.NewReplacer(":", "", "-", "", " ", "").Replace(uid))
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(normalizedUid))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// FindUserByTokenUid returns the active user the tag belongs to and marks the tag as used.
+func FindUserByTokenUid(uid string) (*User, error) {
+	var token UserToken
+	if err := DB.Where("uid_hash = ?", HashTokenUid(uid)).First(&token).Error; err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := DB.Where("user_id = ? AND is_active = ?", token.UserId, true).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	DB.Model(&token).Update("last_used_at", time.Now())
+	return &user, nil
+}
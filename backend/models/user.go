@@ -1,6 +1,8 @@
 package models
 
 import (
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,8 +13,15 @@ type User struct {
 	UserID       uuid.UUID      `json:"id" gorm:"primaryKey;unique;type:uuid;default:gen_random_uuid()"`
 	Name         string         `json:"name" gorm:"index,unique"`
 	Image        string         `json:"image" default:"assets/empty.webp"`
-	Password     string         `json:"password,omitempty"`
+	Password     string         `json:"-"`
+	PinHash      string         `json:"-"` // optional pin for the quick login at the kiosk
+	PinAttempts  int            `json:"-" gorm:"default:0"`
+	PinLockUntil *time.Time     `json:"-"`
+	FailedLogins int            `json:"-" gorm:"default:0"` // failed password logins in a row
+	LockedUntil  *time.Time     `json:"-"`
+	OidcSubject  *string        `json:"-" gorm:"uniqueIndex"` // subject of the linked account at the identity provider
 	Balance      int            `json:"balance" gorm:"default:0"`
+	CreditLimit  *int           `json:"credit_limit"` // how far the balance may go below zero, null uses the global default
 	IsTrusted    bool           `json:"is_trusted" gorm:"default:false"`
 	IsAdmin      bool           `json:"is_admin" gorm:"default:false"`
 	IsActive     bool           `json:"is_active" gorm:"default:true"`
@@ -20,4 +29,87 @@ type User struct {
 	CreatedAt    time.Time      `json:"created_at"`
 	UsedAt       time.Time      `json:"used_at"`
 	DeletedAt    gorm.DeletedAt `json:"deleted_at"`
+	Roles        []Role         `json:"roles,omitempty" gorm:"many2many:user_roles;joinForeignKey:UserId;joinReferences:RoleId"`
+
+	EffectiveCreditLimit int `json:"effective_credit_limit" gorm:"-"` // CreditLimit or the global default
+}
+
+func (u *User) AfterFind(tx *gorm.DB) error {
+	u.EffectiveCreditLimit = u.CalculateCreditLimit()
+	return nil
+}
+
+// CalculateCreditLimit returns how far the balance of the user may go below zero. Users without an own credit limit
+// get DEFAULT_CREDIT_LIMIT, trusted users get DEFAULT_TRUSTED_CREDIT_LIMIT if it is set. The guest user never gets
+// credit, because nobody would owe the debt.
+func (u *User) CalculateCreditLimit() int {
+	if u.UserID == uuid.Nil {
+		return 0
+	}
+	if u.CreditLimit != nil {
+		return *u.CreditLimit
+	}
+
+	defaultLimit, _ := strconv.Atoi(os.Getenv("DEFAULT_CREDIT_LIMIT"))
+	if u.IsTrusted {
+		if trustedLimit, err := strconv.Atoi(os.Getenv("DEFAULT_TRUSTED_CREDIT_LIMIT")); err == nil {
+			return trustedLimit
+		}
+	}
+	return defaultLimit
+}
+
+// PublicUser is the part of a user which is shown to everyone, e.g. in the user picker of the kiosk.
+type PublicUser struct {
+	UserID uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Image  string    `json:"image"`
+}
+
+// PrivateUser contains all details of a user except secrets. It is only returned to the user themselves and to
+// admins.
+type PrivateUser struct {
+	UserID               uuid.UUID      `json:"id"`
+	Name                 string         `json:"name"`
+	Image                string         `json:"image"`
+	HasPin               bool           `json:"has_pin"`
+	LockedUntil          *time.Time     `json:"locked_until,omitempty"`
+	OidcLinked           bool           `json:"oidc_linked"`
+	Balance              int            `json:"balance"`
+	CreditLimit          *int           `json:"credit_limit"`
+	EffectiveCreditLimit int            `json:"effective_credit_limit"`
+	IsTrusted            bool           `json:"is_trusted"`
+	IsAdmin              bool           `json:"is_admin"`
+	IsActive             bool           `json:"is_active"`
+	IsRestricted         bool           `json:"is_restricted"`
+	CreatedAt            time.Time      `json:"created_at"`
+	UsedAt               time.Time      `json:"used_at"`
+	DeletedAt            gorm.DeletedAt `json:"deleted_at"`
+	Roles                []Role         `json:"roles,omitempty"`
+}
+
+func (u *User) Public() PublicUser {
+	return PublicUser{UserID: u.UserID, Name: u.Name, Image: u.Image}
+}
+
+func (u *User) Private() PrivateUser {
+	return PrivateUser{
+		UserID:               u.UserID,
+		Name:                 u.Name,
+		Image:                u.Image,
+		HasPin:               u.PinHash != "",
+		LockedUntil:          u.LockedUntil,
+		OidcLinked:           u.OidcSubject != nil,
+		Balance:              u.Balance,
+		CreditLimit:          u.CreditLimit,
+		EffectiveCreditLimit: u.CalculateCreditLimit(),
+		IsTrusted:            u.IsTrusted,
+		IsAdmin:              u.IsAdmin,
+		IsActive:             u.IsActive,
+		IsRestricted:         u.IsRestricted,
+		CreatedAt:            u.CreatedAt,
+		UsedAt:               u.UsedAt,
+		DeletedAt:            u.DeletedAt,
+		Roles:                u.Roles,
+	}
 }